@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/* prefixes are the (optional) prefixes that integers given in
+   an explicit base can have, and that they are written out with. */
+var prefixes = map[int]string{
+	2:  "0b",
+	8:  "0",
+	16: "0x",
+}
+
+/* baseOf works out the base that an integer option takes its
+   values in, per its `base` tag; without one, it's base 10.  A base
+   of 0 means any base, going by the prefix: `0x` for hexadecimal, `0o`
+   (or just `0`) for octal, `0b` for binary, and none for decimal. */
+func baseOf(field reflect.StructField, t reflect.Type, unit string) (int, error) {
+	tag, set := field.Tag.Lookup("base")
+	if !set {
+		return 10, nil
+	}
+
+	base, err := strconv.Atoi(tag)
+	if err != nil || base == 1 || base < 0 || base > 36 {
+		return 0, fmt.Errorf("invalid base tag '%s' on field %s (should be 0, or between 2 and 36)", tag, field.Name)
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if !integral(t) {
+		return 0, fmt.Errorf("invalid base tag '%s' on field %s (only integers have a base)", tag, field.Name)
+	}
+	if unit != "" {
+		return 0, fmt.Errorf("invalid base tag '%s' on field %s (byte sizes are always given in base 10)", tag, field.Name)
+	}
+	return base, nil
+}
+
+/* integral figures out if a type is one of the integer types. */
+func integral(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+/* baseify parses an integer in the given base (or in any base, by
+   its prefix, for base 0) into a value of the given integer type.
+   For bases 2, 8 and 16, the usual prefix (`0b`, `0` or `0o`, and
+   `0x`) is allowed, but optional.  Underscores between digits are only
+   allowed in base 0, the same as strconv.ParseInt() does it. */
+func baseify(raw string, base int, t reflect.Type) (reflect.Value, error) {
+	s, sign := raw, ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		s, sign = s[1:], s[:1]
+	}
+	p := prefixes[base]
+	if base == 8 && len(s) > 2 && strings.EqualFold(s[:2], "0o") {
+		p = "0o"
+	}
+	if p != "" && len(s) > len(p) && strings.EqualFold(s[:len(p)], p) {
+		s = s[len(p):]
+	}
+	s = sign + s
+
+	var (
+		v   interface{}
+		err error
+	)
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err = strconv.ParseInt(s, base, t.Bits())
+	default:
+		v, err = strconv.ParseUint(s, base, t.Bits())
+	}
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return reflect.ValueOf(nil), fmt.Errorf("integer `%s` is out of range", raw)
+		}
+		if base == 0 {
+			return reflect.ValueOf(nil), fmt.Errorf("invalid integer `%s`", raw)
+		}
+		return reflect.ValueOf(nil), fmt.Errorf("invalid base-%d integer `%s`", base, raw)
+	}
+	return reflect.ValueOf(v).Convert(t), nil
+}
+
+/* based writes out an integer (sans sign) in the given base, with
+   its prefix (if it has one); base 0 integers are written in base 10. */
+func based(n uint64, base int) string {
+	switch base {
+	case 0, 10:
+		return strconv.FormatUint(n, 10)
+	case 8:
+		if n == 0 {
+			return "0"
+		}
+	}
+	return prefixes[base] + strconv.FormatUint(n, base)
+}
@@ -1,10 +1,25 @@
 package cli_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"testing/quick"
+	"time"
 
 	"github.com/jhunt/go-cli"
 )
@@ -18,6 +33,67 @@ func ll(args ...string) []string {
 	return args
 }
 
+/* benchmarkOptions is a middling-sized options structure, with
+   a handful of sub-commands, for the benchmarks to chew on. */
+type benchmarkOptions struct {
+	Debug   bool     `cli:"-D, --debug, --no-debug" help:"Enable debugging."`
+	Target  string   `cli:"-t, --target" help:"The target to operate on."`
+	Format  *string  `cli:"--format"`
+	Timeout int      `cli:"-T, --timeout"`
+	Include []string `cli:"-I, --include"`
+
+	Users struct {
+		List struct {
+			All    bool   `cli:"-a, --all"`
+			Filter string `cli:"-f, --filter"`
+		} `cli:"list, ls"`
+		Delete struct {
+			Force  bool   `cli:"-F, --force"`
+			Reason string `cli:"-r, --reason"`
+		} `cli:"delete, rm"`
+	} `cli:"users, u"`
+
+	Gen struct {
+		Length  uint16   `cli:"-l, --length"`
+		Policy  []string `cli:"-p, --policy"`
+		Symbols bool     `cli:"-S, --symbols, --no-symbols"`
+	} `cli:"gen, g"`
+
+	Exec struct{} `cli:"exec!"`
+}
+
+func BenchmarkNewParser(b *testing.B) {
+	args := []string{"-D", "--target", "prod", "users", "delete", "-F", "--reason", "gone", "jhunt"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var opt benchmarkOptions
+		if _, err := cli.NewParser(&opt, args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseArgs(b *testing.B) {
+	args := []string{"-t", "prod", "gen", "-l", "32", "-p", "upper", "-p", "digits", "--no-symbols"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var opt benchmarkOptions
+		if _, _, err := cli.ParseArgs(&opt, args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseString(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var opt benchmarkOptions
+		if _, _, err := cli.ParseString(&opt, "-D users ls --all --filter 'name=j*'"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 var _ = Describe("CLI", func() {
 	var (
 		cmd      string
@@ -893,7 +969,7 @@ var _ = Describe("CLI", func() {
 
 			Users struct {
 				List struct {
-					All bool `cli"-a, --all"`
+					All bool `cli:"-a, --all"`
 				} `cli:"list"`
 			} `cli:"users"`
 		}{}
@@ -1491,5 +1567,3079 @@ var _ = Describe("CLI", func() {
 		})
 	})
 
+	// }}}
+	Describe("Hidden and deprecated things", func() { // {{{
+		var opt = struct {
+			Help    bool   `cli:"-h, --help"`
+			Secret  bool   `cli:"--secret" hidden:"true"`
+			Old     string `cli:"-o, --old" deprecated:"use --new instead"`
+			New     string `cli:"--new"`
+			Ancient bool   `cli:"--ancient" deprecated:""`
+
+			Legacy struct {
+				Force bool `cli:"-f, --force"`
+			} `cli:"legacy, leg" deprecated:"use 'modern' instead" hidden:"true"`
+
+			Modern struct {
+			} `cli:"modern"`
+		}{}
+
+		BeforeEach(func() {
+			opt.Help = false
+			opt.Secret = false
+			opt.Old = ""
+			opt.New = ""
+			opt.Ancient = false
+			opt.Legacy.Force = false
+		})
+
+		It("Parses hidden options", func() {
+			_, _, err = cli.ParseArgs(&opt, ll("--secret"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Secret).Should(BeTrue())
+		})
+
+		It("Parses deprecated options, with a warning", func() {
+			p, err := cli.NewParser(&opt, ll("--old", "value", "-o", "other"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Old).Should(Equal("other"))
+
+			Ω(len(p.Warnings)).Should(Equal(2))
+			Ω(p.Warnings[0]).Should(Equal("flag `--old` is deprecated; use --new instead"))
+			Ω(p.Warnings[1]).Should(Equal("flag `-o` is deprecated; use --new instead"))
+		})
+
+		It("Does not warn about non-deprecated options", func() {
+			p, err := cli.NewParser(&opt, ll("--new", "value", "-h", "modern"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("modern"))
+			Ω(p.Warnings).Should(BeEmpty())
+		})
+
+		It("Warns about deprecated options without any advice", func() {
+			p, err := cli.NewParser(&opt, ll("--ancient"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Ancient).Should(BeTrue())
+			Ω(p.Warnings).Should(Equal([]string{"flag `--ancient` is deprecated"}))
+		})
+
+		It("Parses hidden, deprecated sub-commands, with a warning", func() {
+			p, err := cli.NewParser(&opt, ll("leg", "-f"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("legacy"))
+			Ω(opt.Legacy.Force).Should(BeTrue())
+			Ω(p.Warnings).Should(Equal([]string{"sub-command `leg` is deprecated; use 'modern' instead"}))
+		})
+
+		It("Sends warnings to the configured sink", func() {
+			var seen []string
+			_, _, err = cli.ParseArgs(&opt, ll("--old", "x", "legacy"), cli.WarnTo(func(msg string) {
+				seen = append(seen, msg)
+			}))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(seen).Should(Equal([]string{
+				"flag `--old` is deprecated; use --new instead",
+				"sub-command `legacy` is deprecated; use 'modern' instead",
+			}))
+		})
+
+		It("Complains about malformed hidden tags", func() {
+			var bad = struct {
+				Flag bool `cli:"--flag" hidden:"sometimes"`
+			}{}
+			_, _, err = cli.ParseArgs(&bad, ll())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("invalid hidden tag"))
+		})
+	})
+
+	// }}}
+	Describe("Default sub-commands", func() { // {{{
+		var opt = struct {
+			Debug bool `cli:"-D, --debug"`
+
+			Status struct {
+				Long bool `cli:"-l, --long"`
+			} `cli:"status*, st"`
+
+			Users struct {
+				List struct {
+					All bool `cli:"-a, --all"`
+				} `cli:"list, ls*"`
+
+				Delete struct {
+				} `cli:"delete"`
+			} `cli:"users"`
+		}{}
+
+		BeforeEach(func() {
+			opt.Debug = false
+			opt.Status.Long = false
+			opt.Users.List.All = false
+		})
+
+		It("Runs the default sub-command when given only global options", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("-D"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("status"))
+			Ω(leftover).Should(BeEmpty())
+			Ω(opt.Debug).Should(BeTrue())
+		})
+
+		It("Runs the default sub-command when given nothing at all", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("status"))
+			Ω(leftover).Should(BeEmpty())
+		})
+
+		It("Passes unrecognized sub-commands to the default as arguments", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("thing", "-l"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("status"))
+			Ω(leftover).Should(Equal([]string{"thing"}))
+			Ω(opt.Status.Long).Should(BeTrue())
+		})
+
+		It("Lets the default sub-command handle its own flags", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("-D", "-l"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("status"))
+			Ω(opt.Debug).Should(BeTrue())
+			Ω(opt.Status.Long).Should(BeTrue())
+		})
+
+		It("Still complains about flags nobody recognizes", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("-D", "-x"))
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("unrecognized.*-x"))
+		})
+
+		It("Still honors explicit sub-commands", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("users", "delete", "jhunt"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("users delete"))
+			Ω(leftover).Should(Equal([]string{"jhunt"}))
+		})
+
+		It("Descends into defaults at every level", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("users", "-a"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("users list"))
+			Ω(leftover).Should(BeEmpty())
+			Ω(opt.Users.List.All).Should(BeTrue())
+
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("users", "jhunt"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("users list"))
+			Ω(leftover).Should(Equal([]string{"jhunt"}))
+		})
+
+		It("Runs the default sub-command in chains", func() {
+			p, err := cli.NewParser(&opt, ll("-D", "users", "--", "st", "-l", "--", "x"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("users list"))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("status"))
+			Ω(opt.Status.Long).Should(BeTrue())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("status"))
+			Ω(p.Args).Should(Equal([]string{"x"}))
+			Ω(opt.Status.Long).Should(BeFalse())
+
+			Ω(p.Next()).Should(BeFalse())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+		})
+
+		It("Falls into the default sub-command after a leading separator", func() {
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("--", "x"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("status"))
+			Ω(leftover).Should(Equal([]string{"x"}))
+
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("-D", "--"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("status"))
+			Ω(leftover).Should(BeEmpty())
+
+			p, err := cli.NewParser(&opt, ll("--", "x"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("status"))
+			Ω(p.Args).Should(Equal([]string{"x"}))
+		})
+
+		It("Only handles each flag in a bundle once, when falling back", func() {
+			var opt = struct {
+				Old  bool     `cli:"-o" deprecated:"x"`
+				Tags []string `cli:"-t"`
+
+				Run struct {
+					Force bool `cli:"-f"`
+				} `cli:"run*"`
+			}{}
+
+			p, err := cli.NewParser(&opt, ll("-otxf"))
+			Ω(err).Should(BeNil())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+			Ω(p.Command).Should(Equal("run"))
+			Ω(p.Warnings).Should(Equal([]string{"flag `-o` is deprecated; x"}))
+			Ω(opt.Tags).Should(Equal([]string{"xf"}))
+
+			p, err = cli.NewParser(&opt, ll("-ofo"))
+			Ω(err).Should(BeNil())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+			Ω(p.Warnings).Should(HaveLen(2))
+			Ω(opt.Run.Force).Should(BeTrue())
+		})
+
+		It("Only runs the implied default sub-command once", func() {
+			p, err := cli.NewParser(&opt, ll("-D"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("status"))
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Complains about multiple default sub-commands", func() {
+			var bad = struct {
+				A struct{} `cli:"a*"`
+				B struct{} `cli:"b*"`
+			}{}
+			_, _, err = cli.ParseArgs(&bad, ll())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("cannot both be the default"))
+		})
+	})
+
+	// }}}
+	Describe("Response files", func() { // {{{
+		var opt = struct {
+			Debug  bool   `cli:"-D, --debug"`
+			Target string `cli:"-t, --target"`
+
+			Set struct {
+				IfMissing bool `cli:"--if-missing"`
+			} `cli:"set"`
+		}{}
+
+		var dir string
+
+		BeforeEach(func() {
+			opt.Debug = false
+			opt.Target = ""
+			opt.Set.IfMissing = false
+
+			dir, err = ioutil.TempDir("", "go-cli-test")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		file := func(name, contents string) string {
+			path := filepath.Join(dir, name)
+			Ω(ioutil.WriteFile(path, []byte(contents), 0644)).Should(Succeed())
+			return path
+		}
+
+		It("Leaves @file arguments alone by default", func() {
+			path := file("args", "-D\n")
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("set", "@"+path))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(leftover).Should(Equal([]string{"@" + path}))
+			Ω(opt.Debug).Should(BeFalse())
+		})
+
+		It("Expands @file arguments into words", func() {
+			path := file("args", `
+# the target environment
+-t 'my target' \
+   -D
+set "key with \"quotes\"" it\'s  # and a comment
+`)
+			cmd, leftover, err = cli.ParseArgs(&opt, ll("@"+path, "--if-missing"), cli.ResponseFiles())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("set"))
+			Ω(leftover).Should(Equal([]string{`key with "quotes"`, "it's"}))
+			Ω(opt.Target).Should(Equal("my target"))
+			Ω(opt.Debug).Should(BeTrue())
+			Ω(opt.Set.IfMissing).Should(BeTrue())
+		})
+
+		It("Handles empty quoted words", func() {
+			path := file("args", `set '' ""`)
+			_, leftover, err = cli.ParseArgs(&opt, ll("@"+path), cli.ResponseFiles())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(leftover).Should(Equal([]string{"", ""}))
+		})
+
+		It("Expands response files in chained commands", func() {
+			path := file("chain", "set a 1 -- set b 2 --if-missing")
+			p, err := cli.NewParser(&opt, ll("-D", "@"+path, "--", "set", "c", "3"), cli.ResponseFiles())
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Args).Should(Equal([]string{"a", "1"}))
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Args).Should(Equal([]string{"b", "2"}))
+			Ω(opt.Set.IfMissing).Should(BeTrue())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Args).Should(Equal([]string{"c", "3"}))
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Includes response files recursively, relative to the including file", func() {
+			Ω(os.Mkdir(filepath.Join(dir, "sub"), 0755)).Should(Succeed())
+			file("sub/inner", "-D")
+			path := file("outer", "-t x\n@sub/inner set")
+			cmd, _, err = cli.ParseArgs(&opt, ll("@"+path), cli.ResponseFiles())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("set"))
+			Ω(opt.Target).Should(Equal("x"))
+			Ω(opt.Debug).Should(BeTrue())
+		})
+
+		It("Treats @@ as an escaped at-sign", func() {
+			path := file("args", "set @@one")
+			_, leftover, err = cli.ParseArgs(&opt, ll("@"+path, "@@two"), cli.ResponseFiles())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(leftover).Should(Equal([]string{"@one", "@two"}))
+		})
+
+		It("Complains about missing response files", func() {
+			_, _, err = cli.ParseArgs(&opt, ll("@"+filepath.Join(dir, "nope")), cli.ResponseFiles())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("unable to read response file `.*/nope`"))
+		})
+
+		It("Complains about missing nested response files, with file and line", func() {
+			path := file("outer", "-D\n\n@missing")
+			_, _, err = cli.ParseArgs(&opt, ll("@"+path), cli.ResponseFiles())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("^.*/outer:3: unable to read response file `.*/missing`"))
+		})
+
+		It("Complains about quoting problems, with file and line", func() {
+			path := file("args", "-D\nset 'oops\n\n")
+			_, _, err = cli.ParseArgs(&opt, ll("@"+path), cli.ResponseFiles())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("^.*/args:2: unterminated single-quoted string"))
+		})
+
+		It("Complains about cycles", func() {
+			file("a", "-D @b")
+			file("b", "@c")
+			file("c", "set\n@a")
+			_, _, err = cli.ParseArgs(&opt, ll("@"+filepath.Join(dir, "a")), cli.ResponseFiles())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("^.*/c:2: response file `.*/a` includes itself \\(via .*/a -> .*/b -> .*/c -> .*/a\\)"))
+		})
+	})
+
+	// }}}
+	Describe("Command strings", func() { // {{{
+		var opt = struct {
+			Debug bool `cli:"-D, --debug"`
+
+			Gen struct {
+				Length int    `cli:"-l, --length"`
+				Policy string `cli:"-p, --policy"`
+			} `cli:"gen"`
+		}{}
+
+		BeforeEach(func() {
+			opt.Debug = false
+			opt.Gen.Length = 0
+			opt.Gen.Policy = ""
+		})
+
+		It("Parses a command string", func() {
+			cmd, leftover, err = cli.ParseString(&opt, `-D gen -l 32 'my path' -p "a b"`)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cmd).Should(Equal("gen"))
+			Ω(leftover).Should(Equal([]string{"my path"}))
+			Ω(opt.Debug).Should(BeTrue())
+			Ω(opt.Gen.Length).Should(Equal(32))
+			Ω(opt.Gen.Policy).Should(Equal("a b"))
+		})
+
+		It("Complains about unbalanced quotes", func() {
+			_, _, err = cli.ParseString(&opt, `gen "my path`)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(MatchRegexp("unterminated double-quoted string"))
+		})
+
+		It("Splits words like a POSIX shell", func() {
+			Ω(cli.Split(``)).Should(BeEmpty())
+			Ω(cli.Split(`  a   b  `)).Should(Equal([]string{"a", "b"}))
+			Ω(cli.Split(`a\ b 'c d' "e f"`)).Should(Equal([]string{"a b", "c d", "e f"}))
+			Ω(cli.Split(`'$HOME' "$HOME" \$HOME`)).Should(Equal([]string{"$HOME", "$HOME", "$HOME"}))
+			Ω(cli.Split(`"a \"b\" \\ \c"`)).Should(Equal([]string{`a "b" \ \c`}))
+			Ω(cli.Split(`'a \"b\"'`)).Should(Equal([]string{`a \"b\"`}))
+			Ω(cli.Split(`x'y'"z"`)).Should(Equal([]string{"xyz"}))
+			Ω(cli.Split(`a '' b`)).Should(Equal([]string{"a", "", "b"}))
+			Ω(cli.Split("a \\\nb")).Should(Equal([]string{"a", "b"}))
+			Ω(cli.Split(`a # b c`)).Should(Equal([]string{"a"}))
+			Ω(cli.Split(`a#b`)).Should(Equal([]string{"a#b"}))
+		})
+
+		It("Quotes arguments only when necessary", func() {
+			Ω(cli.Quote(ll("gen", "-l", "32", "--policy=a-z"))).Should(Equal("gen -l 32 --policy=a-z"))
+			Ω(cli.Quote(ll("my path", "", "it's", "$HOME"))).Should(Equal(`'my path' '' 'it'\''s' '$HOME'`))
+		})
+
+		It("Quotes arguments such that they split back out the same", func() {
+			args := ll("plain", "two words", "", "it's", `"double"`, `back\slash`, "#hash", "new\nline", "*")
+			Ω(cli.Split(cli.Quote(args))).Should(Equal(args))
+		})
+	})
+
+	// }}}
+	Describe("Interactive shells", func() { // {{{
+		type Options struct {
+			Debug  bool   `cli:"-D, --debug"`
+			Target string `cli:"-t, --target"`
+			Token  string `cli:"--token" hidden:"true"`
+
+			Set struct {
+				IfMissing bool `cli:"-m, --if-missing"`
+			} `cli:"set"`
+
+			List struct {
+				All bool `cli:"-a, --all"`
+			} `cli:"list, ls"`
+
+			Users struct {
+				Delete struct {
+				} `cli:"delete, rm"`
+			} `cli:"users"`
+
+			Debugger struct {
+			} `cli:"debugger" hidden:"true"`
+
+			Exec struct {
+			} `cli:"exec!"`
+		}
+
+		var (
+			opt Options
+			ran []string
+			out *bytes.Buffer
+		)
+
+		handler := func(command string, args []string) error {
+			if command == "set" && len(args) == 0 {
+				return fmt.Errorf("set requires arguments")
+			}
+			ran = append(ran, fmt.Sprintf("%s %v [D=%v t=%s m=%v a=%v]", command, args,
+				opt.Debug, opt.Target, opt.Set.IfMissing, opt.List.All))
+			return nil
+		}
+
+		BeforeEach(func() {
+			opt = Options{Target: "default"}
+			ran = nil
+			out = &bytes.Buffer{}
+		})
+
+		It("Runs each line as a chain of commands, resetting in between", func() {
+			err = cli.Shell(&opt, handler, strings.NewReader(
+				"-D set a 1 -m -- list\n"+
+					"\n"+
+					"ls -t other\n"), out, cli.Prompt(""))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ran).Should(Equal([]string{
+				"set [a 1] [D=true t=default m=true a=false]",
+				"list [] [D=true t=default m=false a=false]",
+				"list [] [D=false t=other m=false a=false]",
+			}))
+			Ω(opt.Target).Should(Equal("other"))
+		})
+
+		It("Prints errors and skips the rest of the chain", func() {
+			err = cli.Shell(&opt, handler, strings.NewReader(
+				"set -- list\n"+
+					"list --bogus\n"+
+					"list 'oops\n"+
+					"list\n"), out, cli.Prompt(""))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ran).Should(Equal([]string{
+				"list [] [D=false t=default m=false a=false]",
+			}))
+			Ω(out.String()).Should(Equal(
+				"!!! set requires arguments\n" +
+					"!!! unrecognized flag `--bogus`\n" +
+					"!!! line 1: unterminated single-quoted string\n" +
+					"\n"))
+		})
+
+		It("Prompts for each line", func() {
+			err = cli.Shell(&opt, handler, strings.NewReader("list\nlist\n"), out)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(out.String()).Should(Equal("> > > \n"))
+		})
+
+		It("Stops when told to exit", func() {
+			err = cli.Shell(&opt, handler, strings.NewReader("list\nexit\nlist\n"), out, cli.Prompt(""))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(len(ran)).Should(Equal(1))
+		})
+
+		It("Lists the visible sub-commands for help", func() {
+			err = cli.Shell(&opt, handler, strings.NewReader("help\n"), out, cli.Prompt(""))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(out.String()).Should(Equal(
+				"commands:\n" +
+					"  exec\n" +
+					"  list (ls)\n" +
+					"  set\n" +
+					"  users\n" +
+					"  users delete (rm)\n" +
+					"\n"))
+		})
+
+		It("Persists history to a file", func() {
+			dir, err := ioutil.TempDir("", "go-cli-test")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "history")
+
+			err = cli.Shell(&opt, handler, strings.NewReader("list -a\nset x\n"), out, cli.Prompt(""), cli.History(path))
+			Ω(err).ShouldNot(HaveOccurred())
+			b, err := ioutil.ReadFile(path)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(b)).Should(Equal("list -a\nset x\n"))
+
+			out.Reset()
+			err = cli.Shell(&opt, handler, strings.NewReader("ls\nhistory\n"), out, cli.Prompt(""), cli.History(path))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(out.String()).Should(Equal(
+				"    1  list -a\n" +
+					"    2  set x\n" +
+					"    3  ls\n" +
+					"    4  history\n" +
+					"\n"))
+		})
+
+		It("Completes sub-commands", func() {
+			Ω(cli.Complete(&opt, "")).Should(Equal([]string{"exec", "list", "ls", "set", "users"}))
+			Ω(cli.Complete(&opt, "l")).Should(Equal([]string{"list", "ls"}))
+			Ω(cli.Complete(&opt, "-D users ")).Should(Equal([]string{"delete", "rm"}))
+			Ω(cli.Complete(&opt, "-t users ")).Should(Equal([]string{"exec", "list", "ls", "set", "users"}))
+			Ω(cli.Complete(&opt, "-t users u")).Should(Equal([]string{"users"}))
+			Ω(cli.Complete(&opt, "list something ")).Should(BeEmpty())
+			Ω(cli.Complete(&opt, "list something -- s")).Should(Equal([]string{"set"}))
+		})
+
+		It("Completes flags", func() {
+			Ω(cli.Complete(&opt, "-")).Should(Equal([]string{"--debug", "--target", "-D", "-t"}))
+			Ω(cli.Complete(&opt, "--t")).Should(Equal([]string{"--target"}))
+			Ω(cli.Complete(&opt, "list -")).Should(Equal([]string{"--all", "--debug", "--target", "-D", "-a", "-t"}))
+			Ω(cli.Complete(&opt, "-Dt x set --")).Should(Equal([]string{"--debug", "--if-missing", "--target"}))
+			Ω(cli.Complete(&opt, "-t ")).Should(BeEmpty())
+			Ω(cli.Complete(&opt, "exec -")).Should(BeEmpty())
+		})
+	})
+
+	// }}}
+	Describe("Manual pages", func() { // {{{
+		var opt = struct {
+			Help  bool   `cli:"-h, --help" help:"Show the help."`
+			Token string `cli:"--token" hidden:"true"`
+			URL   string `cli:"-U, --url" help:"The URL of the vault."`
+
+			Gen struct {
+				Length int      `cli:"-l, --length" help:"How long to make the password."`
+				Tags   []string `cli:"-t, --tag"`
+			} `cli:"gen, g" help:"Generate a password."`
+
+			Exec struct {
+			} `cli:"exec!" deprecated:"use 'run' instead"`
+
+			Debug struct {
+			} `cli:"debug" hidden:"true"`
+		}{}
+
+		BeforeEach(func() {
+			opt.Gen.Length = 48
+		})
+
+		It("Renders a single manual page", func() {
+			page, err := cli.ManPage(&opt, cli.ManOptions{
+				Name:    "safe",
+				Date:    "2017-03-09",
+				Version: "1.2.3",
+				Summary: "a vault CLI",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page).Should(Equal(`.TH "SAFE" "1" "2017-03-09" "safe 1.2.3" "safe Manual"
+.SH "NAME"
+safe \- a vault CLI
+.SH "SYNOPSIS"
+.B safe
+[\fIOPTIONS\fR]
+\fICOMMAND\fR
+[\fIARGUMENTS\fR...]
+.SH "OPTIONS"
+.TP
+\fB\-h\fR, \fB\-\-help\fR
+Show the help.
+.TP
+\fB\-U\fR, \fB\-\-url\fR \fIVALUE\fR
+The URL of the vault.
+.SH "COMMANDS"
+.SS "exec"
+The ` + "`exec`" + ` sub-command is deprecated; use 'run' instead.
+.PP
+No options are recognized after ` + "`exec`" + `; all remaining arguments are passed along as-is.
+.SS "gen"
+Generate a password.
+.PP
+Also available as: g.
+.TP
+\fB\-l\fR, \fB\-\-length\fR \fIN\fR
+How long to make the password.
+.br
+Defaults to 48.
+.TP
+\fB\-t\fR, \fB\-\-tag\fR \fIVALUE\fR
+May be given more than once.
+`))
+		})
+
+		It("Renders one manual page per sub-command", func() {
+			pages, err := cli.ManPages(&opt, cli.ManOptions{Name: "safe", Section: "8"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(len(pages)).Should(Equal(3))
+			Ω(pages).Should(HaveKey("safe.8"))
+			Ω(pages).Should(HaveKey("safe-exec.8"))
+			Ω(pages).Should(HaveKey("safe-gen.8"))
+
+			Ω(pages["safe.8"]).Should(ContainSubstring("See \\fBsafe-gen\\fR(8)."))
+			Ω(pages["safe-gen.8"]).Should(HavePrefix(`.TH "SAFE-GEN" "8" "" "safe" "safe Manual"
+.SH "NAME"
+safe-gen \- Generate a password.
+.SH "SYNOPSIS"
+.B safe
+[\fIOPTIONS\fR]
+\fBgen\fR
+[\fIOPTIONS\fR]
+[\fIARGUMENTS\fR...]
+`))
+			Ω(pages["safe-gen.8"]).Should(ContainSubstring(".SH \"GLOBAL OPTIONS\"\n.TP\n\\fB\\-h\\fR, \\fB\\-\\-help\\fR\n"))
+			Ω(pages["safe-gen.8"]).Should(HaveSuffix(".SH \"SEE ALSO\"\n\\fBsafe\\fR(8)\n"))
+		})
+
+		It("Escapes text that troff would otherwise interpret", func() {
+			var odd = struct {
+				Path string `cli:"--path" help:".dotted, with a \\backslash"`
+			}{}
+			page, err := cli.ManPage(&odd, cli.ManOptions{Name: "odd"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page).Should(ContainSubstring("\n\\&.dotted, with a \\ebackslash\n"))
+		})
+	})
+
+	// }}}
+	Describe("Markdown reference documentation", func() { // {{{
+		var opt = struct {
+			Help  bool   `cli:"-h, --help" help:"Show the help."`
+			Token string `cli:"--token" hidden:"true"`
+
+			Gen struct {
+				Length int      `cli:"-l, --length" help:"How long to make the password."`
+				Tags   []string `cli:"-t, --tag" help:"Tags (a | b)."`
+			} `cli:"gen, g" help:"Generate a password."`
+
+			Users struct {
+				Delete struct {
+				} `cli:"delete"`
+			} `cli:"users"`
+
+			Debug struct {
+			} `cli:"debug" hidden:"true"`
+		}{}
+
+		BeforeEach(func() {
+			opt.Gen.Length = 48
+		})
+
+		It("Renders a single document", func() {
+			doc, err := cli.Markdown(&opt, cli.MarkdownOptions{Name: "safe", Version: "1.2", Summary: "A vault CLI."})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(doc).Should(Equal("# safe 1.2\n" +
+				"\n" +
+				"A vault CLI.\n" +
+				"\n" +
+				"## Contents\n" +
+				"\n" +
+				"- [safe](#safe-12)\n" +
+				"  - [safe gen](#safe-gen)\n" +
+				"  - [safe users](#safe-users)\n" +
+				"    - [safe users delete](#safe-users-delete)\n" +
+				"\n" +
+				"```\nsafe [OPTIONS] COMMAND [ARGUMENTS...]\n```\n" +
+				"\n" +
+				"## Options\n" +
+				"\n" +
+				"| Flags | Value | Default | Description |\n" +
+				"| ----- | ----- | ------- | ----------- |\n" +
+				"| `-h`, `--help` |  |  | Show the help. |\n" +
+				"\n" +
+				"## Commands\n" +
+				"\n" +
+				"- [safe gen](#safe-gen) - Generate a password.\n" +
+				"- [safe users](#safe-users)\n" +
+				"\n" +
+				"## safe gen\n" +
+				"\n" +
+				"Generate a password.\n" +
+				"\n" +
+				"Also available as: g.\n" +
+				"\n" +
+				"```\nsafe [OPTIONS] gen [OPTIONS] [ARGUMENTS...]\n```\n" +
+				"\n" +
+				"### Options\n" +
+				"\n" +
+				"| Flags | Value | Default | Description |\n" +
+				"| ----- | ----- | ------- | ----------- |\n" +
+				"| `-l`, `--length` | `N` | `48` | How long to make the password. |\n" +
+				"| `-t`, `--tag` | `VALUE` |  | Tags (a \\| b). May be given more than once. |\n" +
+				"\n" +
+				"Options from [safe](#safe-12) may also be given.\n" +
+				"\n" +
+				"## safe users\n" +
+				"\n" +
+				"```\nsafe [OPTIONS] users COMMAND [ARGUMENTS...]\n```\n" +
+				"\n" +
+				"Options from [safe](#safe-12) may also be given.\n" +
+				"\n" +
+				"### Commands\n" +
+				"\n" +
+				"- [safe users delete](#safe-users-delete)\n" +
+				"\n" +
+				"## safe users delete\n" +
+				"\n" +
+				"```\nsafe [OPTIONS] users delete [ARGUMENTS...]\n```\n" +
+				"\n" +
+				"Options from [safe users](#safe-users) may also be given.\n"))
+		})
+
+		It("Renders the same document every time", func() {
+			first, err := cli.Markdown(&opt, cli.MarkdownOptions{Name: "safe"})
+			Ω(err).ShouldNot(HaveOccurred())
+			for i := 0; i < 10; i++ {
+				Ω(cli.Markdown(&opt, cli.MarkdownOptions{Name: "safe"})).Should(Equal(first))
+			}
+		})
+
+		It("Renders one document per sub-command", func() {
+			docs, err := cli.MarkdownFiles(&opt, cli.MarkdownOptions{Name: "safe"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(len(docs)).Should(Equal(4))
+			Ω(docs).Should(HaveKey("safe.md"))
+			Ω(docs).Should(HaveKey("safe-gen.md"))
+			Ω(docs).Should(HaveKey("safe-users.md"))
+			Ω(docs).Should(HaveKey("safe-users-delete.md"))
+
+			Ω(docs["safe.md"]).Should(ContainSubstring("- [safe gen](safe-gen.md) - Generate a password.\n"))
+			Ω(docs["safe-users-delete.md"]).Should(Equal("# safe users delete\n" +
+				"\n" +
+				"```\nsafe [OPTIONS] users delete [ARGUMENTS...]\n```\n" +
+				"\n" +
+				"Options from [safe users](safe-users.md) may also be given.\n" +
+				"\n" +
+				"See also: [safe users](safe-users.md)\n"))
+		})
+	})
+
+	// }}}
+
+	Describe("Machine-readable specs", func() { // {{{
+		type Options struct {
+			Debug  bool    `cli:"-D, --debug, --no-debug" help:"Enable debugging."`
+			Level  int8    `cli:"-l, --level"`
+			Owner  *string `cli:"-o, --owner"`
+			Target string  `cli:"-t, --target" hidden:"true" deprecated:"use --url instead"`
+			Ratio  float64 `cli:"--ratio"`
+			Gen    struct {
+				Length uint16   `cli:"-L, --length"`
+				Policy []string `cli:"-p, --policy"`
+			} `cli:"gen, g, generate" help:"Generate a password."`
+			Exec struct{} `cli:"exec!"`
+		}
+
+		It("Describes the command tree", func() {
+			opt := Options{}
+			opt.Ratio = 0.5
+			opt.Gen.Policy = []string{"upper", "digits"}
+
+			spec, err := cli.Spec(&opt)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec.Name).Should(Equal(""))
+			Ω(len(spec.Options)).Should(Equal(5))
+			Ω(len(spec.Commands)).Should(Equal(2))
+
+			debug := spec.Options[0]
+			Ω(debug.Field).Should(Equal("Debug"))
+			Ω(debug.Kind).Should(Equal("bool"))
+			Ω(debug.Shorts).Should(Equal([]string{"D"}))
+			Ω(debug.Longs).Should(Equal([]string{"debug", "no-debug"}))
+			Ω(debug.Value).Should(BeFalse())
+			Ω(debug.Help).Should(Equal("Enable debugging."))
+			Ω(debug.Default).Should(BeNil())
+
+			level := spec.Options[1]
+			Ω(level.Kind).Should(Equal("int8"))
+			Ω(level.Value).Should(BeTrue())
+			Ω(level.Nullable).Should(BeFalse())
+			Ω(level.Min).Should(Equal(json.Number("-128")))
+			Ω(level.Max).Should(Equal(json.Number("127")))
+
+			owner := spec.Options[2]
+			Ω(owner.Kind).Should(Equal("string"))
+			Ω(owner.Nullable).Should(BeTrue())
+			Ω(owner.Min).Should(Equal(json.Number("")))
+
+			target := spec.Options[3]
+			Ω(target.Hidden).Should(BeTrue())
+			Ω(target.Deprecated).ShouldNot(BeNil())
+			Ω(*target.Deprecated).Should(Equal("use --url instead"))
+
+			Ω(spec.Options[4].Default).Should(Equal(0.5))
+
+			exec := spec.Commands[0]
+			Ω(exec.Name).Should(Equal("exec"))
+			Ω(exec.Stop).Should(BeTrue())
+			Ω(exec.Aliases).Should(Equal([]string{}))
+
+			gen := spec.Commands[1]
+			Ω(gen.Name).Should(Equal("gen"))
+			Ω(gen.Field).Should(Equal("Gen"))
+			Ω(gen.Aliases).Should(Equal([]string{"g", "generate"}))
+			Ω(gen.Stop).Should(BeFalse())
+			Ω(gen.Help).Should(Equal("Generate a password."))
+			Ω(gen.Options[0].Min).Should(Equal(json.Number("0")))
+			Ω(gen.Options[0].Max).Should(Equal(json.Number("65535")))
+			Ω(gen.Options[1].Repeatable).Should(BeTrue())
+			Ω(gen.Options[1].Kind).Should(Equal("string"))
+			Ω(gen.Options[1].Default).Should(Equal([]string{"upper", "digits"}))
+		})
+
+		It("Renders the command tree as JSON", func() {
+			opt := Options{}
+			spec, err := cli.Spec(&opt)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			b, err := json.Marshal(spec)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(b)).Should(ContainSubstring(`"name":"exec","field":"Exec","aliases":[],"stop":true`))
+			Ω(string(b)).Should(ContainSubstring(`"commands":[]`))
+			Ω(string(b)).Should(ContainSubstring(`"field":"Level","kind":"int8","shorts":["l"],"longs":["level"],"value":true,"repeatable":false,"nullable":false,"min":-128,"max":127`))
+
+			b, err = json.Marshal(&cli.CommandSpec{Name: "empty"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(b)).Should(Equal(`{"name":"empty","aliases":[],"stop":false,"hidden":false,"options":[],"commands":[]}`))
+		})
+
+		It("Renders a JSON Schema for the option values", func() {
+			opt := Options{}
+			opt.Gen.Length = 32
+			spec, err := cli.Spec(&opt)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			b, err := spec.JSONSchema()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var schema map[string]interface{}
+			Ω(json.Unmarshal(b, &schema)).Should(Succeed())
+			Ω(schema["$schema"]).Should(Equal("http://json-schema.org/draft-07/schema#"))
+			Ω(schema["type"]).Should(Equal("object"))
+
+			props := schema["properties"].(map[string]interface{})
+			Ω(props).Should(HaveKey("Debug"))
+			Ω(props["Debug"]).Should(Equal(map[string]interface{}{
+				"type":        "boolean",
+				"description": "Enable debugging.",
+			}))
+			Ω(props["Owner"]).Should(Equal(map[string]interface{}{
+				"type": []interface{}{"string", "null"},
+			}))
+			Ω(props["Level"]).Should(Equal(map[string]interface{}{
+				"type":    "integer",
+				"minimum": -128.0,
+				"maximum": 127.0,
+			}))
+			Ω(props["Target"]).Should(HaveKeyWithValue("deprecated", true))
+			Ω(props["Ratio"]).Should(Equal(map[string]interface{}{"type": "number"}))
+
+			gen := props["Gen"].(map[string]interface{})
+			Ω(gen["type"]).Should(Equal("object"))
+			Ω(gen["title"]).Should(Equal("gen"))
+			Ω(gen["description"]).Should(Equal("Generate a password."))
+			sub := gen["properties"].(map[string]interface{})
+			Ω(sub["Length"]).Should(HaveKeyWithValue("default", 32.0))
+			Ω(sub["Policy"]).Should(Equal(map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			}))
+		})
+
+		It("Refuses to describe invalid options structures", func() {
+			opt := struct {
+				A bool `cli:"-a"`
+				B bool `cli:"-a"`
+			}{}
+			_, err := cli.Spec(&opt)
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	// }}}
+
+	Describe("Marshaling options back into arguments", func() { // {{{
+		type Options struct {
+			Debug   bool     `cli:"-D, --debug, --no-debug"`
+			Verbose bool     `cli:"-v, --verbose"`
+			Color   *bool    `cli:"--color, --no-color"`
+			Name    string   `cli:"-n, --name"`
+			Owner   *string  `cli:"-o, --owner"`
+			Count   int      `cli:"-c"`
+			Small   int8     `cli:"--small"`
+			Big     uint64   `cli:"--big"`
+			Ratio   float32  `cli:"--ratio"`
+			Tags    []string `cli:"-t, --tag"`
+			Ports   []uint16 `cli:"-p, --port"`
+			Gen     struct {
+				Length int      `cli:"-l, --length"`
+				Policy []string `cli:"--policy"`
+				Strict bool     `cli:"--strict, --no-strict"`
+			} `cli:"gen, g"`
+			Exec struct {
+				Shell string `cli:"--shell"`
+			} `cli:"exec!"`
+		}
+
+		/* nil and empty lists parse the same */
+		normalize := func(o *Options) {
+			if len(o.Tags) == 0 {
+				o.Tags = nil
+			}
+			if len(o.Ports) == 0 {
+				o.Ports = nil
+			}
+			if len(o.Gen.Policy) == 0 {
+				o.Gen.Policy = nil
+			}
+		}
+
+		It("Renders a canonical argument list", func() {
+			opt := Options{}
+			opt.Debug = true
+			opt.Name = "my thing"
+			opt.Count = 3
+			opt.Tags = []string{"a", "b"}
+			opt.Gen.Length = 42
+			opt.Gen.Policy = []string{"upper"}
+
+			argv, err := cli.Marshal(&opt, "g")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(argv).Should(Equal([]string{
+				"--debug", "--name", "my thing", "-c", "3", "--tag", "a", "--tag", "b",
+				"gen", "--length", "42", "--policy", "upper",
+			}))
+		})
+
+		It("Leaves out sub-commands and options that aren't on the path", func() {
+			opt := Options{}
+			opt.Small = -4
+			opt.Gen.Length = 42
+
+			argv, err := cli.Marshal(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(argv).Should(Equal([]string{"--small", "-4"}))
+		})
+
+		It("Renders booleans that are off in their --no- form", func() {
+			opt := Options{}
+			off := false
+			opt.Color = &off
+
+			argv, err := cli.Marshal(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(argv).Should(Equal([]string{"--no-color"}))
+
+			dflt := Options{}
+			dflt.Debug = true
+			dflt.Gen.Strict = true
+			argv, err = cli.MarshalWith(&opt, "gen", cli.MarshalOptions{Defaults: &dflt})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(argv).Should(Equal([]string{"--no-debug", "--no-color", "gen", "--no-strict"}))
+		})
+
+		It("Leaves out values that match the defaults", func() {
+			opt := Options{}
+			opt.Name = "thing"
+			opt.Count = 3
+
+			dflt := Options{}
+			dflt.Name = "thing"
+			argv, err := cli.MarshalWith(&opt, "", cli.MarshalOptions{Defaults: &dflt})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(argv).Should(Equal([]string{"-c", "3"}))
+		})
+
+		It("Includes every value it can, if asked to", func() {
+			opt := Options{}
+			argv, err := cli.MarshalWith(&opt, "exec", cli.MarshalOptions{All: true})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(argv).Should(Equal([]string{
+				"--no-debug", "--name", "", "-c", "0", "--small", "0", "--big", "0", "--ratio", "0",
+				"exec",
+			}))
+		})
+
+		It("Complains about values that can't be expressed", func() {
+			dflt := Options{}
+			dflt.Verbose = true
+			_, err := cli.MarshalWith(&Options{}, "", cli.MarshalOptions{Defaults: &dflt})
+			Ω(err).Should(MatchError("unable to marshal `--verbose` flag: there is no `--no-` form to turn it off"))
+
+			owner := "root"
+			dflt = Options{Owner: &owner}
+			_, err = cli.MarshalWith(&Options{}, "", cli.MarshalOptions{Defaults: &dflt})
+			Ω(err).Should(MatchError("unable to marshal `--owner` flag: there is no way to unset it"))
+
+			dflt = Options{Ports: []uint16{80}}
+			_, err = cli.MarshalWith(&Options{}, "", cli.MarshalOptions{Defaults: &dflt})
+			Ω(err).Should(MatchError("unable to marshal `--port` flag: there is no way to empty it out"))
+
+			opt := Options{}
+			opt.Exec.Shell = "/bin/sh"
+			_, err = cli.Marshal(&opt, "exec")
+			Ω(err).Should(MatchError("unable to marshal options for `exec` sub-command: no options are recognized after it"))
+
+			_, err = cli.Marshal(&opt, "gen nope")
+			Ω(err).Should(MatchError("unrecognized sub-command `nope`"))
+
+			_, err = cli.MarshalWith(&opt, "", cli.MarshalOptions{Defaults: &struct{}{}})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("Round-trips through ParseArgs()", func() {
+			roundtrip := func(in Options, gen bool) bool {
+				command := ""
+				if gen {
+					command = "gen"
+				} else {
+					in.Gen = Options{}.Gen
+				}
+				in.Exec = Options{}.Exec
+				normalize(&in)
+
+				argv, err := cli.Marshal(&in, command)
+				if err != nil {
+					return false
+				}
+
+				var out Options
+				cmd, args, err := cli.ParseArgs(&out, argv)
+				return err == nil && cmd == command && len(args) == 0 && reflect.DeepEqual(in, out)
+			}
+			Ω(quick.Check(roundtrip, nil)).Should(Succeed())
+		})
+
+		It("Round-trips through ParseArgs(), from non-zero defaults", func() {
+			roundtrip := func(in, dflt Options) bool {
+				in.Exec, dflt.Exec = Options{}.Exec, Options{}.Exec
+				normalize(&in)
+				normalize(&dflt)
+
+				/* steer clear of the values that can't be expressed */
+				if dflt.Verbose {
+					in.Verbose = true
+				}
+				if in.Owner == nil {
+					in.Owner = dflt.Owner
+				}
+				if in.Color == nil {
+					in.Color = dflt.Color
+				}
+				if in.Tags == nil {
+					in.Tags = dflt.Tags
+				}
+				if in.Ports == nil {
+					in.Ports = dflt.Ports
+				}
+				if in.Gen.Policy == nil {
+					in.Gen.Policy = dflt.Gen.Policy
+				}
+
+				argv, err := cli.MarshalWith(&in, "gen", cli.MarshalOptions{Defaults: &dflt})
+				if err != nil {
+					return false
+				}
+
+				out := dflt
+				cmd, args, err := cli.ParseArgs(&out, argv)
+				return err == nil && cmd == "gen" && len(args) == 0 && reflect.DeepEqual(in, out)
+			}
+			Ω(quick.Check(roundtrip, nil)).Should(Succeed())
+		})
+	})
+
+	// }}}
+
+	Describe("Parsing the same type of options over and over", func() { // {{{
+		type Options struct {
+			Tags  []string `cli:"-t, --tag"`
+			Owner *string  `cli:"-o, --owner"`
+			Users struct {
+				Force bool `cli:"-f, --force"`
+			} `cli:"users, u, user"`
+		}
+
+		It("Starts fresh with every new structure", func() {
+			for i := 0; i < 3; i++ {
+				opt := Options{Tags: []string{"default"}}
+				command, args, err := cli.ParseArgs(&opt, ll("-t", "a", "u", "-f", "-t", "b", "x"))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(command).Should(Equal("users"))
+				Ω(args).Should(Equal(ll("x")))
+				Ω(opt.Tags).Should(Equal(ll("a", "b")))
+				Ω(opt.Users.Force).Should(BeTrue())
+				Ω(opt.Owner).Should(BeNil())
+			}
+		})
+
+		It("Writes through pointers that are already set", func() {
+			owner := "nobody"
+			opt := Options{Owner: &owner}
+			_, _, err := cli.ParseArgs(&opt, ll("--owner", "root"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(owner).Should(Equal("root"))
+
+			opt = Options{}
+			_, _, err = cli.ParseArgs(&opt, ll("--owner", "jhunt"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Owner).ShouldNot(BeNil())
+			Ω(*opt.Owner).Should(Equal("jhunt"))
+			Ω(owner).Should(Equal("root"))
+		})
+
+		It("Follows other pointers, whatever they point to", func() {
+			type Options struct {
+				Level *int `cli:"-l, --level"`
+			}
+
+			level := 3
+			opt := Options{Level: &level}
+			_, _, err := cli.ParseArgs(&opt, ll("-l", "4"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(level).Should(Equal(4))
+
+			_, _, err = cli.ParseArgs(&Options{}, ll("-l", "4"))
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("Reports defaults from each new structure", func() {
+			for _, length := range []int{16, 32} {
+				opt := struct {
+					Length int `cli:"-l, --length"`
+				}{Length: length}
+				spec, err := cli.Spec(&opt)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(spec.Options[0].Default).Should(Equal(length))
+			}
+		})
+
+		It("Doesn't hang on to the structures it has seen", func() {
+			type Options struct {
+				Name string `cli:"-n, --name"`
+			}
+
+			var collected int32
+			func() {
+				opt := &Options{}
+				runtime.SetFinalizer(opt, func(*Options) { atomic.StoreInt32(&collected, 1) })
+				_, _, err := cli.ParseArgs(opt, ll("-n", "first"))
+				Ω(err).ShouldNot(HaveOccurred())
+			}()
+
+			Eventually(func() int32 {
+				runtime.GC()
+				return atomic.LoadInt32(&collected)
+			}).Should(Equal(int32(1)))
+		})
+
+		It("Validates the structure before completing", func() {
+			_, err := cli.Complete(&struct {
+				All bool `cli:"-a, --all"`
+				Any bool `cli:"-a, --any"`
+			}{}, "-")
+			Ω(err).Should(MatchError("short option `-a` reused ambiguously (at global level)"))
+		})
+	})
+
+	// }}}
+	Describe("Resetting options between chained commands", func() { // {{{
+		type Options struct {
+			Debug bool     `cli:"-D, --debug"`
+			Tags  []string `cli:"-t, --tag"`
+			Owner *string  `cli:"-o, --owner"`
+
+			Seen map[string]int
+
+			Run struct {
+				Force bool `cli:"-f, --force"`
+			} `cli:"run"`
+
+			Check struct {
+				Deep bool `cli:"-d, --deep"`
+			} `cli:"check"`
+		}
+
+		It("Puts global options back the way they were after the globals", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("-t", "a", "run", "-t", "b", "-D", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("a", "b")))
+			Ω(opt.Debug).Should(BeTrue())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("a")))
+			Ω(opt.Debug).Should(BeFalse())
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Only resets the options of the last command", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("run", "-f", "--", "check", "-d", "--", "check"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("run"))
+			Ω(opt.Run.Force).Should(BeTrue())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("check"))
+			Ω(opt.Run.Force).Should(BeFalse())
+			Ω(opt.Check.Deep).Should(BeTrue())
+
+			/* changes to options of other commands are left alone */
+			opt.Run.Force = true
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("check"))
+			Ω(opt.Run.Force).Should(BeTrue())
+			Ω(opt.Check.Deep).Should(BeFalse())
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Leaves fields that aren't options alone", func() {
+			opt := Options{Seen: map[string]int{}}
+			p, err := cli.NewParser(&opt, ll("run", "--", "check"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			for p.Next() {
+				opt.Seen[p.Command]++
+			}
+			Ω(opt.Seen).Should(Equal(map[string]int{"run": 1, "check": 1}))
+		})
+
+		It("Deep-copies lists, so that changing them in place doesn't stick", func() {
+			opt := Options{Tags: []string{"x", "y"}}
+			p, err := cli.NewParser(&opt, ll("run", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			opt.Tags[0] = "changed"
+			opt.Tags = append(opt.Tags, "more")
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("x", "y")))
+		})
+
+		It("Lets each command replace a default list, rather than append to it", func() {
+			opt := Options{Tags: []string{"x"}}
+			p, err := cli.NewParser(&opt, ll("run", "-t", "a", "--", "run", "-t", "b", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("a")))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("b")))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("x")))
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Keeps appending to lists the globals started", func() {
+			opt := Options{Tags: []string{"x"}}
+			p, err := cli.NewParser(&opt, ll("-t", "a", "run", "-t", "b", "--", "run", "-t", "c"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("a", "b")))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("a", "c")))
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Restores pointers that were already set by writing through them", func() {
+			owner := "nobody"
+			opt := Options{Owner: &owner}
+			p, err := cli.NewParser(&opt, ll("run", "-o", "root", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(owner).Should(Equal("root"))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Owner).Should(BeIdenticalTo(&owner))
+			Ω(owner).Should(Equal("nobody"))
+		})
+
+		It("Restores pointers that weren't set back to nil", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("run", "-o", "root", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Owner).ShouldNot(BeNil())
+			Ω(*opt.Owner).Should(Equal("root"))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Owner).Should(BeNil())
+		})
+
+		It("Doesn't share storage with pointers set by the globals", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("-o", "root", "run", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			*opt.Owner = "changed"
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(*opt.Owner).Should(Equal("root"))
+		})
+
+		It("Follows pointers to other types", func() {
+			type Options struct {
+				Level *int `cli:"-l, --level"`
+				Run   struct {
+				} `cli:"run"`
+			}
+
+			level := 3
+			opt := Options{Level: &level}
+			p, err := cli.NewParser(&opt, ll("run", "-l", "7", "--", "run"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(level).Should(Equal(7))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(level).Should(Equal(3))
+		})
+
+		It("Resets options in interactive shells, between lines", func() {
+			opt := Options{Tags: []string{"default"}}
+			var (
+				tags  [][]string
+				force []bool
+			)
+			err := cli.Shell(&opt, func(command string, args []string) error {
+				tags = append(tags, append([]string{}, opt.Tags...))
+				force = append(force, opt.Run.Force)
+				opt.Tags[0] = "changed"
+				return nil
+			}, strings.NewReader("run -t a -f\nrun\n"), ioutil.Discard)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tags).Should(Equal([][]string{ll("a"), ll("default")}))
+			Ω(force).Should(Equal([]bool{true, false}))
+		})
+	})
+
+	// }}}
+	Describe("Parsing in parallel", func() { // {{{
+		type Options struct {
+			Debug bool     `cli:"-D, --debug"`
+			Tags  []string `cli:"-t, --tag"`
+			Owner *string  `cli:"-o, --owner"`
+
+			Run struct {
+				Level int `cli:"-l, --level"`
+			} `cli:"run"`
+		}
+
+		/* parallel runs fn in n goroutines, all at once,
+		   and gives back whatever errors they ran into */
+		parallel := func(n int, fn func(i int) error) []error {
+			var (
+				wg   sync.WaitGroup
+				errs = make(chan error, n)
+			)
+			wg.Add(n)
+			for i := 0; i < n; i++ {
+				go func(i int) {
+					defer wg.Done()
+					if err := fn(i); err != nil {
+						errs <- err
+					}
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+
+			l := []error{}
+			for err := range errs {
+				l = append(l, err)
+			}
+			return l
+		}
+
+		/* check makes sure that the i'th parse came out the way it should have */
+		check := func(i int, opt *Options, command string) error {
+			tag := fmt.Sprintf("t%d", i)
+			if command != "run" {
+				return fmt.Errorf("#%d: command was '%s', not 'run'", i, command)
+			}
+			if len(opt.Tags) != 1 || opt.Tags[0] != tag {
+				return fmt.Errorf("#%d: tags were %v, not [%s]", i, opt.Tags, tag)
+			}
+			if opt.Owner == nil || *opt.Owner != tag {
+				return fmt.Errorf("#%d: owner was not '%s'", i, tag)
+			}
+			if opt.Run.Level != i {
+				return fmt.Errorf("#%d: level was %d, not %d", i, opt.Run.Level, i)
+			}
+			return nil
+		}
+
+		It("Can parse separate values of the same type at the same time", func() {
+			errs := parallel(64, func(i int) error {
+				tag := fmt.Sprintf("t%d", i)
+				opt := Options{Tags: []string{"default"}}
+				p, err := cli.NewParser(&opt, ll("run", "-t", tag, "-o", tag, "-l", fmt.Sprintf("%d", i), "--", "run", "-D"))
+				if err != nil {
+					return err
+				}
+				if !p.Next() {
+					return fmt.Errorf("#%d: no first command", i)
+				}
+				if err := check(i, &opt, p.Command); err != nil {
+					return err
+				}
+				if !p.Next() || !opt.Debug || opt.Tags[0] != "default" || opt.Owner != nil || opt.Run.Level != 0 {
+					return fmt.Errorf("#%d: second command did not reset options", i)
+				}
+				return p.Error()
+			})
+			Ω(errs).Should(BeEmpty())
+		})
+
+		It("Hands out fresh copies of the prototype from a factory", func() {
+			owner := "nobody"
+			f, err := cli.NewFactory(&Options{Tags: []string{"default"}, Owner: &owner})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			a := f.New().(*Options)
+			b := f.New().(*Options)
+			Ω(a).Should(Equal(b))
+			Ω(a.Owner).ShouldNot(BeIdenticalTo(&owner))
+			Ω(a.Owner).ShouldNot(BeIdenticalTo(b.Owner))
+
+			a.Tags[0] = "changed"
+			*a.Owner = "changed"
+			Ω(b.Tags).Should(Equal(ll("default")))
+			Ω(*b.Owner).Should(Equal("nobody"))
+			Ω(f.New().(*Options).Tags).Should(Equal(ll("default")))
+			Ω(owner).Should(Equal("nobody"))
+		})
+
+		It("Ignores changes made to the prototype after the fact", func() {
+			proto := Options{Tags: []string{"default"}}
+			f, err := cli.NewFactory(&proto)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			proto.Tags[0] = "changed"
+			proto.Debug = true
+			Ω(f.New()).Should(Equal(&Options{Tags: []string{"default"}}))
+		})
+
+		It("Can parse into fresh values from a factory at the same time", func() {
+			f, err := cli.NewFactory(&Options{Tags: []string{"default"}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			errs := parallel(64, func(i int) error {
+				tag := fmt.Sprintf("t%d", i)
+				thing, command, args, err := f.ParseArgs(ll("-t", tag, "-o", tag, "run", "-l", fmt.Sprintf("%d", i), "arg"))
+				if err != nil {
+					return err
+				}
+				if len(args) != 1 || args[0] != "arg" {
+					return fmt.Errorf("#%d: args were %v, not [arg]", i, args)
+				}
+				return check(i, thing.(*Options), command)
+			})
+			Ω(errs).Should(BeEmpty())
+		})
+
+		It("Applies factory settings, and then per-call settings", func() {
+			type Options struct {
+				Old bool `cli:"--old" deprecated:"use --new"`
+			}
+
+			var mine, theirs []string
+			f, err := cli.NewFactory(&Options{}, cli.WarnTo(func(msg string) { theirs = append(theirs, msg) }))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			thing, p, err := f.NewParser(ll("--old"), cli.WarnTo(func(msg string) { mine = append(mine, msg) }))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(thing.(*Options).Old).Should(BeTrue())
+			Ω(p.Warnings).Should(HaveLen(1))
+			Ω(mine).Should(Equal(p.Warnings))
+			Ω(theirs).Should(BeEmpty())
+		})
+
+		It("Refuses to make a factory for things it can't parse", func() {
+			_, err := cli.NewFactory(&struct {
+				Level *int `cli:"-l, --level"`
+			}{})
+			Ω(err).Should(HaveOccurred())
+
+			_, err = cli.NewFactory(Options{})
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	// }}}
+	Describe("Running chained commands with a context", func() { // {{{
+		type Options struct {
+			Set struct {
+				Force bool `cli:"-f, --force"`
+			} `cli:"set"`
+			Build struct {
+				Count int  `cli:"-n, --count"`
+				Old   bool `cli:"--old" deprecated:"use --count instead"`
+			} `cli:"build"`
+			List struct{} `cli:"list"`
+		}
+		chain := ll("set", "a", "-f", "--", "build", "vm", "--", "list")
+
+		It("Hands each command (and the context) to the handler", func() {
+			type key struct{}
+			opt := Options{}
+			p, err := cli.NewParser(&opt, chain)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var ran []string
+			ctx := context.WithValue(context.Background(), key{}, "value")
+			err = p.Run(ctx, func(ctx context.Context, command string, args []string) error {
+				Ω(ctx.Value(key{})).Should(Equal("value"))
+				ran = append(ran, command+" "+strings.Join(args, " "))
+				if command == "set" {
+					Ω(opt.Set.Force).Should(BeTrue())
+				}
+				return nil
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+			Ω(ran).Should(Equal(ll("set a", "build vm", "list ")))
+		})
+
+		It("Stops at the first error from the handler", func() {
+			p, err := cli.NewParser(&Options{}, chain)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var ran []string
+			err = p.Run(context.Background(), func(ctx context.Context, command string, args []string) error {
+				ran = append(ran, command)
+				if command == "build" {
+					return fmt.Errorf("build failed")
+				}
+				return nil
+			})
+			Ω(err).Should(MatchError("build failed"))
+			Ω(p.Error()).Should(MatchError("build failed"))
+			Ω(ran).Should(Equal(ll("set", "build")))
+		})
+
+		It("Stops at the first parsing error", func() {
+			p, err := cli.NewParser(&Options{}, ll("set", "--", "build", "--bogus", "--", "list"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var ran []string
+			err = p.Run(context.Background(), func(ctx context.Context, command string, args []string) error {
+				ran = append(ran, command)
+				return nil
+			})
+			Ω(err).Should(MatchError("unrecognized flag `--bogus`"))
+			Ω(ran).Should(Equal(ll("set")))
+		})
+
+		It("Skips the rest of the chain once the context is canceled", func() {
+			p, err := cli.NewParser(&Options{}, chain)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var ran []string
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err = p.Run(ctx, func(ctx context.Context, command string, args []string) error {
+				ran = append(ran, command)
+				if command == "set" {
+					cancel()
+				}
+				return nil
+			})
+			Ω(err).Should(Equal(cli.ErrCanceled))
+			Ω(p.Error()).Should(Equal(cli.ErrCanceled))
+			Ω(ran).Should(Equal(ll("set")))
+		})
+
+		It("Doesn't parse the commands it skips", func() {
+			p, err := cli.NewParser(&Options{}, ll("set", "--", "build", "--old", "-n", "x"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var ran []string
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err = p.Run(ctx, func(ctx context.Context, command string, args []string) error {
+				ran = append(ran, command)
+				cancel()
+				return nil
+			})
+			Ω(err).Should(Equal(cli.ErrCanceled))
+			Ω(p.Error()).Should(Equal(cli.ErrCanceled))
+			Ω(p.Warnings).Should(BeEmpty())
+			Ω(ran).Should(Equal(ll("set")))
+		})
+
+		It("Blames cancellation for errors from canceled commands", func() {
+			p, err := cli.NewParser(&Options{}, chain)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err = p.Run(ctx, func(ctx context.Context, command string, args []string) error {
+				cancel()
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			Ω(err).Should(Equal(cli.ErrCanceled))
+		})
+
+		It("Doesn't complain about cancellation after the last command", func() {
+			p, err := cli.NewParser(&Options{}, ll("list"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err = p.Run(ctx, func(ctx context.Context, command string, args []string) error {
+				cancel()
+				return nil
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Cancels the context when asked-for signals are caught", func() {
+			/* not os.Interrupt; ginkgo has its own plans for that one */
+			p, err := cli.NewParser(&Options{}, chain, cli.WithSignals(syscall.SIGHUP))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var ran []string
+			err = p.Run(context.Background(), func(ctx context.Context, command string, args []string) error {
+				ran = append(ran, command)
+				self, err := os.FindProcess(os.Getpid())
+				if err != nil {
+					return err
+				}
+				if err := self.Signal(syscall.SIGHUP); err != nil {
+					return err
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(5 * time.Second):
+					return fmt.Errorf("timed out waiting for the signal")
+				}
+			})
+			Ω(err).Should(Equal(cli.ErrCanceled))
+			Ω(ran).Should(Equal(ll("set")))
+		})
+	})
+
+	// }}}
+	Describe("Strict option ordering", func() { // {{{
+		type Options struct {
+			Debug bool `cli:"-D, --debug"`
+
+			Run struct {
+				Force bool   `cli:"-f, --force"`
+				User  string `cli:"-u, --user"`
+
+				As struct{} `cli:"as"`
+			} `cli:"run"`
+		}
+
+		It("Still interleaves flags and arguments by default", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("run", "ls", "-f", "-l"))
+			Ω(err).Should(MatchError("unrecognized flag `-l`"))
+
+			command, args, err = cli.ParseArgs(&opt, ll("run", "ls", "-f"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("run"))
+			Ω(args).Should(Equal(ll("ls")))
+			Ω(opt.Run.Force).Should(BeTrue())
+		})
+
+		It("Stops looking for flags at the first positional argument", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("run", "-u", "root", "ls", "-f", "-l", "--debug"), cli.StrictOrder())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("run"))
+			Ω(args).Should(Equal(ll("ls", "-f", "-l", "--debug")))
+			Ω(opt.Run.User).Should(Equal("root"))
+			Ω(opt.Run.Force).Should(BeFalse())
+			Ω(opt.Debug).Should(BeFalse())
+		})
+
+		It("Stops looking for flags at the top-level, too", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("-D", "file", "-D", "run"), cli.StrictOrder())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal(""))
+			Ω(args).Should(Equal(ll("file", "-D", "run")))
+			Ω(opt.Debug).Should(BeTrue())
+		})
+
+		It("Still recognizes sub-commands before the first argument", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("-D", "run", "-f", "as", "-u", "root", "id", "-u"), cli.StrictOrder())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("run as"))
+			Ω(args).Should(Equal(ll("id", "-u")))
+			Ω(opt.Debug).Should(BeTrue())
+			Ω(opt.Run.Force).Should(BeTrue())
+			Ω(opt.Run.User).Should(Equal("root"))
+
+			command, args, err = cli.ParseArgs(&opt, ll("run", "ls", "as"), cli.StrictOrder())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("run"))
+			Ω(args).Should(Equal(ll("ls", "as")))
+		})
+
+		It("Picks option processing back up in the next chained command", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("run", "ls", "-f", "--", "run", "-f", "ls", "-u", "--", "as"), cli.StrictOrder())
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("run"))
+			Ω(p.Args).Should(Equal(ll("ls", "-f")))
+			Ω(opt.Run.Force).Should(BeFalse())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal("run"))
+			Ω(p.Args).Should(Equal(ll("ls", "-u")))
+			Ω(opt.Run.Force).Should(BeTrue())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Command).Should(Equal(""))
+			Ω(p.Args).Should(Equal(ll("as")))
+			Ω(opt.Run.Force).Should(BeFalse())
+
+			Ω(p.Next()).Should(BeFalse())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+		})
+
+		It("Still reports bad flags that come before any arguments", func() {
+			p, err := cli.NewParser(&Options{}, ll("run", "--bogus", "ls"), cli.StrictOrder())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Next()).Should(BeFalse())
+			Ω(p.Error()).Should(MatchError("unrecognized flag `--bogus`"))
+		})
+	})
+
+	// }}}
+	Describe("Negative numbers", func() { // {{{
+		type Options struct {
+			Verbose bool `cli:"-v, --verbose"`
+
+			Adjust struct {
+				By    int  `cli:"-n, --by"`
+				Force bool `cli:"-f, --force"`
+				Info  bool `cli:"-i"`
+			} `cli:"adjust"`
+
+			Rotate struct {
+				Ninety bool `cli:"-9"`
+			} `cli:"rotate"`
+		}
+
+		It("Treats negative numbers as flags, by default", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("adjust", "-5"))
+			Ω(err).Should(MatchError("unrecognized flag `-5`"))
+		})
+
+		It("Always takes negative numbers as flag values", func() {
+			opt := Options{}
+			_, args, err := cli.ParseArgs(&opt, ll("adjust", "--by", "-5", "x"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Adjust.By).Should(Equal(-5))
+			Ω(args).Should(Equal(ll("x")))
+
+			_, _, err = cli.ParseArgs(&opt, ll("adjust", "-n", "-6"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Adjust.By).Should(Equal(-6))
+
+			_, _, err = cli.ParseArgs(&opt, ll("adjust", "-fn-7"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Adjust.By).Should(Equal(-7))
+			Ω(opt.Adjust.Force).Should(BeTrue())
+		})
+
+		It("Takes negative numbers as arguments, if asked to", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("adjust", "-5", "-2.5", "-f", "-.5", "-1e3", "-0"), cli.NegativeNumbers())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("adjust"))
+			Ω(args).Should(Equal(ll("-5", "-2.5", "-.5", "-1e3", "-0")))
+			Ω(opt.Adjust.Force).Should(BeTrue())
+		})
+
+		It("Takes negative numbers as arguments at the top-level, too", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("-v", "-10", "adjust"), cli.NegativeNumbers())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal(""))
+			Ω(args).Should(Equal(ll("-10", "adjust")))
+			Ω(opt.Verbose).Should(BeTrue())
+		})
+
+		It("Prefers numeric short flags, when there are any in scope", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("rotate", "-9", "-90"), cli.NegativeNumbers())
+			Ω(err).Should(MatchError("unrecognized flag `-0`"))
+
+			command, args, err = cli.ParseArgs(&opt, ll("rotate", "-9", "-8"), cli.NegativeNumbers())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("rotate"))
+			Ω(args).Should(Equal(ll("-8")))
+			Ω(opt.Rotate.Ninety).Should(BeTrue())
+
+			/* -9 is only a flag for `rotate` */
+			command, args, err = cli.ParseArgs(&opt, ll("adjust", "-9"), cli.NegativeNumbers())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("adjust"))
+			Ω(args).Should(Equal(ll("-9")))
+		})
+
+		It("Doesn't mistake bundles of letters for numbers", func() {
+			/* that's -i, and then -n with a value of "f" */
+			_, _, err := cli.ParseArgs(&Options{}, ll("adjust", "-inf"), cli.NegativeNumbers())
+			Ω(err).Should(MatchError(`strconv.ParseInt: parsing "f": invalid syntax`))
+
+			_, _, err = cli.ParseArgs(&Options{}, ll("adjust", "-5f"), cli.NegativeNumbers())
+			Ω(err).Should(MatchError("unrecognized flag `-5`"))
+
+			_, _, err = cli.ParseArgs(&Options{}, ll("adjust", "-0x10"), cli.NegativeNumbers())
+			Ω(err).Should(MatchError("unrecognized flag `-0`"))
+		})
+
+		It("Lets negative numbers start the argument list of a chained command", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("adjust", "-3", "--", "adjust", "-n", "-4", "-5"), cli.NegativeNumbers())
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Args).Should(Equal(ll("-3")))
+			Ω(opt.Adjust.By).Should(Equal(0))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(p.Args).Should(Equal(ll("-5")))
+			Ω(opt.Adjust.By).Should(Equal(-4))
+
+			Ω(p.Next()).Should(BeFalse())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+		})
+	})
+
+	// }}}
+	Describe("Single-dash long options", func() { // {{{
+		type Options struct {
+			URL      string   `cli:"--url"`
+			Insecure bool     `cli:"--insecure, --no-insecure"`
+			Verbose  bool     `cli:"-v, --verbose"`
+			Tags     []string `cli:"--tag"`
+			Old      bool     `cli:"--old" deprecated:"use -new"`
+			New      bool     `cli:"--new"`
+
+			Fetch struct {
+				Timeout int  `cli:"--timeout"`
+				Quiet   bool `cli:"-q"`
+				Ipv6    bool `cli:"-6"`
+			} `cli:"fetch"`
+		}
+
+		It("Doesn't allow single-dash long options by default", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("-url", "x"))
+			Ω(err).Should(MatchError("unrecognized flag `-u`"))
+		})
+
+		It("Looks up single-dash options as long options, if asked to", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("-url", "https://x", "-insecure", "-tag", "a", "-tag=b", "fetch", "-timeout=30", "-verbose", "y"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("fetch"))
+			Ω(args).Should(Equal(ll("y")))
+			Ω(opt.URL).Should(Equal("https://x"))
+			Ω(opt.Insecure).Should(BeTrue())
+			Ω(opt.Verbose).Should(BeTrue())
+			Ω(opt.Tags).Should(Equal(ll("a", "b")))
+			Ω(opt.Fetch.Timeout).Should(Equal(30))
+		})
+
+		It("Still accepts double-dash long options, and short options", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--url", "x", "fetch", "-vq6"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.URL).Should(Equal("x"))
+			Ω(opt.Verbose).Should(BeTrue())
+			Ω(opt.Fetch.Quiet).Should(BeTrue())
+			Ω(opt.Fetch.Ipv6).Should(BeTrue())
+		})
+
+		It("Takes values in the same argument, with one dash or two", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--url=x", "--tag=a=b", "-tag=c", "--insecure=true", "fetch", "--timeout=30"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.URL).Should(Equal("x"))
+			Ω(opt.Tags).Should(Equal(ll("a=b", "c")))
+			Ω(opt.Insecure).Should(BeTrue())
+			Ω(opt.Fetch.Timeout).Should(Equal(30))
+
+			_, _, err = cli.ParseArgs(&opt, ll("--insecure=maybe"), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("invalid value `maybe` for `--insecure` flag (should be true or false)"))
+
+			_, _, err = cli.ParseArgs(&opt, ll("--no-insecure=true"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Insecure).Should(BeFalse())
+		})
+
+		It("Takes explicit values for boolean options, like the flag package does", func() {
+			opt := Options{Insecure: true}
+			_, _, err := cli.ParseArgs(&opt, ll("-insecure=false", "-verbose=true"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Insecure).Should(BeFalse())
+			Ω(opt.Verbose).Should(BeTrue())
+
+			_, _, err = cli.ParseArgs(&opt, ll("-no-insecure=false"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Insecure).Should(BeTrue())
+
+			_, _, err = cli.ParseArgs(&opt, ll("-insecure=maybe"), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("invalid value `maybe` for `-insecure` flag (should be true or false)"))
+		})
+
+		It("Complains about missing values", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("-url"), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("missing required value for `-url` flag"))
+		})
+
+		It("Warns about deprecated options, as they were given", func() {
+			p, err := cli.NewParser(&Options{}, ll("-old"), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Warnings).Should(Equal(ll("flag `-old` is deprecated; use -new")))
+		})
+
+		It("Only finds long options that are in scope", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("-timeout", "5", "fetch"), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("unrecognized flag `-t`"))
+		})
+
+		It("Rejects long options that could be mistaken for bundles of short options", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Verbose bool `cli:"-v"`
+				Ok      bool `cli:"-o, --ok"`
+				Kill    bool `cli:"-k"`
+			}{}, ll(), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("long option `--ok` is ambiguous when given as `-ok`, which could also mean `-o -k` (at global level)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				User string `cli:"-u, --user"`
+			}{}, ll(), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("long option `--user` is ambiguous when given as `-user`, which could also mean `-u ser` (at global level)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Env string `cli:"--env"`
+
+				Deploy struct {
+					Every bool `cli:"-e"`
+					Name  bool `cli:"-n"`
+					Vars  bool `cli:"-v"`
+				} `cli:"deploy"`
+			}{}, ll(), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("long option `--env` is ambiguous when given as `-env`, which could also mean `-e -n -v` (in `deploy` sub-command)"))
+
+			/* without the setting, none of that matters */
+			_, _, err = cli.ParseArgs(&struct {
+				User string `cli:"-u, --user"`
+			}{}, ll())
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	// }}}
+	Describe("Bridging to the flag package", func() { // {{{
+		type Options struct {
+			Debug bool `cli:"-D, --debug"`
+
+			Run struct {
+				Force bool `cli:"-f, --force"`
+			} `cli:"run, r"`
+		}
+
+		var (
+			fs       *flag.FlagSet
+			toStderr *bool
+			logDir   *string
+			level    *int
+		)
+		BeforeEach(func() {
+			fs = flag.NewFlagSet("logging", flag.ContinueOnError)
+			toStderr = fs.Bool("logtostderr", false, "Log to standard error.")
+			logDir = fs.String("log_dir", "/var/log", "Where to write log files.")
+			level = fs.Int("v", 0, "Verbosity level.")
+		})
+
+		/* set lists the names of the flags that have been set */
+		set := func(fs *flag.FlagSet) []string {
+			l := []string{}
+			fs.Visit(func(f *flag.Flag) { l = append(l, f.Name) })
+			return l
+		}
+
+		It("Mounts a flag set's flags onto the top-level", func() {
+			opt := Options{}
+			command, args, err := cli.ParseArgs(&opt, ll("--logtostderr", "--log_dir", "/tmp", "run", "-v", "3", "-D", "x"), cli.MountFlags("", fs))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(command).Should(Equal("run"))
+			Ω(args).Should(Equal(ll("x")))
+			Ω(opt.Debug).Should(BeTrue())
+			Ω(*toStderr).Should(BeTrue())
+			Ω(*logDir).Should(Equal("/tmp"))
+			Ω(*level).Should(Equal(3))
+			Ω(set(fs)).Should(Equal(ll("log_dir", "logtostderr", "v")))
+		})
+
+		It("Mounts a flag set's flags onto a sub-command, and its aliases", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("r", "-fv", "2"), cli.MountFlags("run", fs))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Run.Force).Should(BeTrue())
+			Ω(*level).Should(Equal(2))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-v", "2", "run"), cli.MountFlags("run", fs))
+			Ω(err).Should(MatchError("unrecognized flag `-v`"))
+		})
+
+		It("Passes along errors from the flag package", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("-v", "lots"), cli.MountFlags("", fs))
+			Ω(err).Should(MatchError("invalid value `lots` for `-v` flag: parse error"))
+		})
+
+		It("Works with single-dash long options", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("-log_dir=/srv", "-logtostderr"), cli.MountFlags("", fs), cli.SingleDashLongs())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(*logDir).Should(Equal("/srv"))
+			Ω(*toStderr).Should(BeTrue())
+		})
+
+		It("Resets mounted flags between chained commands", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("--log_dir", "/a", "run", "-v", "4", "--log_dir", "/b", "--", "run"), cli.MountFlags("", fs))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(*level).Should(Equal(4))
+			Ω(*logDir).Should(Equal("/b"))
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(*level).Should(Equal(0))
+			Ω(*logDir).Should(Equal("/a"))
+			Ω(p.Next()).Should(BeFalse())
+		})
+
+		It("Rejects flags that clash with existing options", func() {
+			fs.Bool("debug", false, "Debugging.")
+			_, _, err := cli.ParseArgs(&Options{}, ll(), cli.MountFlags("run", fs))
+			Ω(err).Should(MatchError("long option `--debug` reused ambiguously (in `run` sub-command)"))
+		})
+
+		It("Rejects flag sets mounted on sub-commands that don't exist", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll(), cli.MountFlags("run walk", fs))
+			Ω(err).Should(MatchError("unable to mount flags on unrecognized sub-command `walk`"))
+		})
+
+		It("Exports a level of the options structure as a flag set", func() {
+			type Options struct {
+				URL      string   `cli:"-u, --url" help:"Where to go."`
+				Insecure bool     `cli:"-k, --insecure, --no-insecure"`
+				Tags     []string `cli:"-t, --tag"`
+
+				Run struct {
+					Force bool `cli:"-f, --force"`
+				} `cli:"run"`
+			}
+
+			opt := Options{Insecure: true, Tags: []string{"default"}}
+			fs, err := cli.FlagSet(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fs.Lookup("url").Usage).Should(Equal("Where to go."))
+			Ω(fs.Lookup("u")).ShouldNot(BeNil())
+			Ω(fs.Lookup("insecure").DefValue).Should(Equal("true"))
+			Ω(fs.Lookup("no-insecure").DefValue).Should(Equal("false"))
+			Ω(fs.Lookup("force")).Should(BeNil())
+
+			err = fs.Parse(ll("-url", "x", "--no-insecure", "-t", "a", "-tag=b", "rest"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fs.Args()).Should(Equal(ll("rest")))
+			Ω(opt.URL).Should(Equal("x"))
+			Ω(opt.Insecure).Should(BeFalse())
+			Ω(opt.Tags).Should(Equal(ll("a", "b")))
+
+			Ω(fs.Set("k", "true")).Should(Succeed())
+			Ω(opt.Insecure).Should(BeTrue())
+			Ω(fs.Set("k", "maybe")).ShouldNot(Succeed())
+
+			fs, err = cli.FlagSet(&opt, "run")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fs.Name()).Should(Equal("run"))
+			Ω(fs.Parse(ll("-f"))).Should(Succeed())
+			Ω(opt.Run.Force).Should(BeTrue())
+
+			_, err = cli.FlagSet(&opt, "walk")
+			Ω(err).Should(MatchError("unrecognized sub-command `walk`"))
+		})
+	})
+
+	// }}}
+	Describe("Delimited list values", func() { // {{{
+		type Options struct {
+			Tags  []string `cli:"-t, --tags" sep:","`
+			Ports []int    `cli:"-p, --ports" sep:":"`
+			Names []string `cli:"-n, --name"`
+		}
+
+		It("Splits values on the separator", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--tags", "a,b,c", "-t", "d", "-p", "80:443", "-n", "x,y"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Tags).Should(Equal(ll("a", "b", "c", "d")))
+			Ω(opt.Ports).Should(Equal([]int{80, 443}))
+			Ω(opt.Names).Should(Equal(ll("x,y")))
+		})
+
+		It("Honors backslash escapes", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-t", `a\,b,c\\,C:\dir`))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Tags).Should(Equal(ll("a,b", `c\`, `C:\dir`)))
+		})
+
+		It("Keeps empty elements, but treats an empty value as an empty list", func() {
+			opt := Options{Tags: []string{"default"}}
+			_, _, err := cli.ParseArgs(&opt, ll("-t", ""))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Tags).Should(Equal([]string{}))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-t", ",a,"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Tags).Should(Equal(ll("", "a", "")))
+		})
+
+		It("Checks every element", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-p", "80:http"))
+			Ω(err).Should(MatchError(`strconv.ParseInt: parsing "http": invalid syntax`))
+			Ω(opt.Ports).Should(BeEmpty())
+		})
+
+		It("Rejects separators on things that aren't lists", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Name string `cli:"--name" sep:","`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid sep tag ',' on field Name (only lists can be delimited)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Names []string `cli:"--name" sep:""`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid sep tag '' on field Names"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Names []string `cli:"--name" sep:"\\"`
+			}{}, ll())
+			Ω(err).Should(MatchError(`invalid sep tag '\' on field Names`))
+		})
+
+		It("Marshals delimited lists as a single, escaped value", func() {
+			opt := Options{Tags: ll("a,b", `c\`, "d"), Ports: []int{1, 2}}
+			args, err := cli.Marshal(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--tags", `a\,b,c\\,d`, "--ports", "1:2")))
+
+			args, err = cli.MarshalWith(&Options{Tags: []string{}}, "", cli.MarshalOptions{Defaults: &Options{Tags: ll("x")}})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--tags", "")))
+
+			_, err = cli.Marshal(&Options{Tags: ll("")}, "")
+			Ω(err).Should(MatchError("unable to marshal `--tags` flag: a lone empty value looks just like an empty list"))
+		})
+
+		It("Round-trips delimited lists through ParseArgs()", func() {
+			roundtrip := func(tags []string, ports []int) bool {
+				if len(tags) == 1 && tags[0] == "" {
+					return true
+				}
+				in := Options{Tags: tags, Ports: ports}
+				argv, err := cli.Marshal(&in, "")
+				if err != nil {
+					return false
+				}
+
+				var out Options
+				if _, _, err = cli.ParseArgs(&out, argv); err != nil {
+					return false
+				}
+				/* nil and empty lists are one and the same */
+				return (len(in.Tags)+len(out.Tags) == 0 || reflect.DeepEqual(in.Tags, out.Tags)) &&
+					(len(in.Ports)+len(out.Ports) == 0 || reflect.DeepEqual(in.Ports, out.Ports))
+			}
+			Ω(quick.Check(roundtrip, nil)).Should(Succeed())
+		})
+
+		It("Reports the separator in the spec", func() {
+			spec, err := cli.Spec(&Options{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec.Options[0].Separator).Should(Equal(","))
+			Ω(spec.Options[2].Separator).Should(Equal(""))
+		})
+	})
+
+	// }}}
+	Describe("Negatable options", func() { // {{{
+		type Options struct {
+			Debug bool     `cli:"-D, --debug" negatable:"true"`
+			Name  string   `cli:"-n, --name" negatable:"true"`
+			Level *string  `cli:"--level" negatable:"true"`
+			Tags  []string `cli:"-t, --tag, --tags" negatable:"true"`
+			Ports []int    `cli:"-p" negatable:"false"`
+
+			Run struct {
+				Only []string `cli:"--only" negatable:"true" sep:","`
+			} `cli:"run"`
+		}
+
+		It("Turns booleans off", func() {
+			opt := Options{Debug: true}
+			_, _, err := cli.ParseArgs(&opt, ll("--no-debug"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Debug).Should(BeFalse())
+		})
+
+		It("Clears lists, overriding their defaults", func() {
+			opt := Options{Tags: ll("a", "b")}
+			_, _, err := cli.ParseArgs(&opt, ll("--no-tag"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Tags).Should(Equal([]string{}))
+
+			opt = Options{Tags: ll("a", "b")}
+			_, _, err = cli.ParseArgs(&opt, ll("-t", "c", "--no-tags", "-t", "d"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Tags).Should(Equal(ll("d")))
+
+			opt = Options{}
+			_, _, err = cli.ParseArgs(&opt, ll("run", "--no-only"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Run.Only).Should(Equal([]string{}))
+		})
+
+		It("Resets pointers to nil, and everything else to its zero value", func() {
+			opt := Options{Name: "default"}
+			_, _, err := cli.ParseArgs(&opt, ll("--level", "high", "--no-level", "--no-name"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Level).Should(BeNil())
+			Ω(opt.Name).Should(Equal(""))
+		})
+
+		It("Does not take a value with the negated form", func() {
+			opt := Options{}
+			_, args, err := cli.ParseArgs(&opt, ll("--no-name", "x"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("x")))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Name string `cli:"--name" negatable:"true"`
+			}{}, ll("-no-name=x"), cli.SingleDashLongs())
+			Ω(err).Should(MatchError("the `-no-name` flag does not take a value"))
+		})
+
+		It("Only negates the options that ask for it", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--no-p"))
+			Ω(err).Should(MatchError("unrecognized flag `--no-p`"))
+		})
+
+		It("Checks the generated names for collisions", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Color   []string `cli:"--color" negatable:"true"`
+				NoColor bool     `cli:"--no-color"`
+			}{}, ll())
+			Ω(err).Should(MatchError("long option `--no-color` reused ambiguously (at global level)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Cache bool `cli:"--cache" negatable:"true"`
+				Sub   struct {
+					NoCache string `cli:"--no-cache"`
+				} `cli:"sub"`
+			}{}, ll())
+			Ω(err).Should(MatchError("long option `--no-cache` reused ambiguously (in `sub` sub-command)"))
+		})
+
+		It("Rejects bad negatable tags", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Name string `cli:"--name" negatable:"maybe"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid negatable tag 'maybe' on field Name"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Name string `cli:"-n" negatable:"true"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid negatable tag on field Name (it has no long options to negate)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Sub struct{} `cli:"sub" negatable:"true"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid negatable tag 'true' on field Sub (only options can be negated)"))
+		})
+
+		It("Marshals cleared values with the negated form", func() {
+			high := "high"
+			args, err := cli.MarshalWith(&Options{Tags: []string{}}, "",
+				cli.MarshalOptions{Defaults: &Options{Tags: ll("x"), Level: &high}})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--no-level", "--no-tag")))
+
+			args, err = cli.MarshalWith(&Options{Debug: false}, "", cli.MarshalOptions{Defaults: &Options{Debug: true}})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--no-debug")))
+		})
+
+		It("Documents the negated forms without listing them as flags", func() {
+			spec, err := cli.Spec(&Options{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec.Options[0].Longs).Should(Equal(ll("debug", "no-debug")))
+			Ω(spec.Options[0].Negations).Should(BeEmpty())
+			Ω(spec.Options[3].Longs).Should(Equal(ll("tag", "tags")))
+			Ω(spec.Options[3].Negations).Should(Equal(ll("no-tag", "no-tags")))
+
+			md, err := cli.Markdown(&Options{}, cli.MarkdownOptions{Name: "tool"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(md).Should(ContainSubstring("| `-t`, `--tag`, `--tags` | `VALUE` |"))
+			Ω(md).Should(ContainSubstring("Can be cleared out with `--no-tag`."))
+		})
+
+		It("Exports the negated forms to a flag.FlagSet", func() {
+			opt := Options{Tags: ll("a")}
+			fs, err := cli.FlagSet(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fs.Parse(ll("--no-tags"))).Should(Succeed())
+			Ω(opt.Tags).Should(Equal([]string{}))
+		})
+	})
+
+	// }}}
+	Describe("Byte sizes and percentages", func() { // {{{
+		type Options struct {
+			Size      cli.ByteSize   `cli:"-s, --size"`
+			Bandwidth uint32         `cli:"--bandwidth" unit:"bytes"`
+			Small     int8           `cli:"--small" unit:"bytes"`
+			Threshold cli.Percent    `cli:"-t, --threshold"`
+			Sizes     []cli.ByteSize `cli:"--sizes" sep:","`
+		}
+
+		It("Parses SI and IEC suffixes", func() {
+			for in, n := range map[string]cli.ByteSize{
+				"0":       0,
+				"512":     512,
+				"512B":    512,
+				"1k":      1000,
+				"1kB":     1000,
+				"1KiB":    1024,
+				"10 MB":   10 * 1000 * 1000,
+				"10MiB":   10 * 1024 * 1024,
+				"10gib":   10 * 1024 * 1024 * 1024,
+				"1.5KiB":  1536,
+				"2TB":     2e12,
+				"3PiB":    3 << 50,
+				"15EiB":   15 << 60,
+				"100MB/s": 100e6,
+			} {
+				opt := Options{}
+				_, _, err := cli.ParseArgs(&opt, ll("--size", in))
+				Ω(err).ShouldNot(HaveOccurred(), in)
+				Ω(opt.Size).Should(Equal(n), in)
+			}
+		})
+
+		It("Rejects sizes that make no sense", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-s", "10XB"))
+			Ω(err).Should(MatchError("invalid byte size `10XB`"))
+			_, _, err = cli.ParseArgs(&opt, ll("-s", "GiB"))
+			Ω(err).Should(MatchError("invalid byte size `GiB`"))
+			_, _, err = cli.ParseArgs(&opt, ll("-s", "-1"))
+			Ω(err).Should(MatchError("invalid byte size `-1`"))
+			_, _, err = cli.ParseArgs(&opt, ll("-s", "1.0001kB"))
+			Ω(err).Should(MatchError("invalid byte size `1.0001kB` (not a whole number of bytes)"))
+		})
+
+		It("Detects overflow, for the width of the integer", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--bandwidth", "4GiB"))
+			Ω(err).Should(MatchError("byte size `4GiB` is out of range"))
+			_, _, err = cli.ParseArgs(&opt, ll("--bandwidth", "4GB"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Bandwidth).Should(Equal(uint32(4e9)))
+
+			_, _, err = cli.ParseArgs(&opt, ll("--small", "127"))
+			Ω(err).ShouldNot(HaveOccurred())
+			_, _, err = cli.ParseArgs(&opt, ll("--small", "128B"))
+			Ω(err).Should(MatchError("byte size `128B` is out of range"))
+			_, _, err = cli.ParseArgs(&opt, ll("--size", "16EiB"))
+			Ω(err).Should(MatchError("byte size `16EiB` is out of range"))
+		})
+
+		It("Parses percentages, with or without the percent sign", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--threshold", "85%"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Threshold).Should(Equal(cli.Percent(85)))
+			Ω(opt.Threshold.Fraction()).Should(Equal(0.85))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-t", "12.5"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Threshold).Should(Equal(cli.Percent(12.5)))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-t", "lots"))
+			Ω(err).Should(MatchError("invalid percentage `lots`"))
+		})
+
+		It("Rejects unit tags that don't apply", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Size int `cli:"--size" unit:"bits"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid unit tag 'bits' on field Size"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Size float64 `cli:"--size" unit:"bytes"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid unit tag 'bytes' on field Size (only integers can be byte sizes)"))
+		})
+
+		It("Formats values canonically", func() {
+			Ω(cli.ByteSize(0).String()).Should(Equal("0B"))
+			Ω(cli.ByteSize(1023).String()).Should(Equal("1023B"))
+			Ω(cli.ByteSize(2048).String()).Should(Equal("2KiB"))
+			Ω(cli.ByteSize(250e6).String()).Should(Equal("250MB"))
+			Ω(cli.ByteSize(10 << 30).String()).Should(Equal("10GiB"))
+			Ω(cli.ByteSize(1536).String()).Should(Equal("1536B"))
+			Ω(cli.Percent(85).String()).Should(Equal("85%"))
+
+			args, err := cli.Marshal(&Options{Size: 10 << 30, Bandwidth: 100e6, Threshold: 85, Sizes: []cli.ByteSize{1024, 1000}}, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--size", "10GiB", "--bandwidth", "100MB", "--threshold", "85%", "--sizes", "1KiB,1kB")))
+
+			md, err := cli.Markdown(&Options{Size: 1 << 20}, cli.MarkdownOptions{Name: "tool"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(md).Should(ContainSubstring("| `-s`, `--size` | `SIZE` | `1MiB` |"))
+			Ω(md).Should(ContainSubstring("| `-t`, `--threshold` | `PERCENT` |"))
+
+			spec, err := cli.Spec(&Options{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec.Options[0].Kind).Should(Equal("uint64"))
+			Ω(spec.Options[0].Unit).Should(Equal("bytes"))
+			Ω(spec.Options[3].Unit).Should(Equal("percent"))
+		})
+
+		It("Round-trips byte sizes through ParseArgs()", func() {
+			roundtrip := func(size uint64, bandwidth uint32) bool {
+				in := Options{Size: cli.ByteSize(size), Bandwidth: bandwidth}
+				argv, err := cli.Marshal(&in, "")
+				if err != nil {
+					return false
+				}
+				var out Options
+				_, _, err = cli.ParseArgs(&out, argv)
+				return err == nil && out.Size == in.Size && out.Bandwidth == in.Bandwidth
+			}
+			Ω(quick.Check(roundtrip, nil)).Should(Succeed())
+		})
+
+		It("Handles other named types too", func() {
+			type Level int
+			type Name string
+			type Flag bool
+			opt := struct {
+				Level Level  `cli:"-l"`
+				Names []Name `cli:"-n"`
+				Flag  Flag   `cli:"-f"`
+			}{}
+			_, _, err := cli.ParseArgs(&opt, ll("-l", "3", "-n", "a", "-f"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Level).Should(Equal(Level(3)))
+			Ω(opt.Names).Should(Equal([]Name{"a"}))
+			Ω(opt.Flag).Should(Equal(Flag(true)))
+		})
+	})
+
+	// }}}
+	Describe("Integer bases", func() { // {{{
+		type Options struct {
+			Count int    `cli:"-c, --count" base:"0"`
+			Mode  uint32 `cli:"-m, --mode" base:"8"`
+			Mask  uint8  `cli:"--mask" base:"16"`
+			Bits  []int8 `cli:"--bits" base:"2"`
+			Plain int    `cli:"--plain"`
+		}
+
+		It("Detects the base from the prefix, for base 0", func() {
+			for in, n := range map[string]int{
+				"42":        42,
+				"0x2a":      42,
+				"0X2A":      42,
+				"0o52":      42,
+				"052":       42,
+				"0b101010":  42,
+				"1_000_000": 1000000,
+				"-0x10":     -16,
+			} {
+				opt := Options{}
+				_, _, err := cli.ParseArgs(&opt, ll("--count", in))
+				Ω(err).ShouldNot(HaveOccurred(), in)
+				Ω(opt.Count).Should(Equal(n), in)
+			}
+		})
+
+		It("Takes explicit bases, with or without the usual prefix", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--mode", "0755", "--mask", "ff", "--bits", "101", "--bits", "-0b11"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Mode).Should(Equal(uint32(0755)))
+			Ω(opt.Mask).Should(Equal(uint8(0xff)))
+			Ω(opt.Bits).Should(Equal([]int8{5, -3}))
+
+			_, _, err = cli.ParseArgs(&opt, ll("--mode", "755", "--mask", "0xF0"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Mode).Should(Equal(uint32(0755)))
+			Ω(opt.Mask).Should(Equal(uint8(0xf0)))
+
+			_, _, err = cli.ParseArgs(&opt, ll("--mode", "0o700"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Mode).Should(Equal(uint32(0700)))
+		})
+
+		It("Leaves options without a base tag in base 10", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--plain", "0x10"))
+			Ω(err).Should(MatchError(`strconv.ParseInt: parsing "0x10": invalid syntax`))
+			_, _, err = cli.ParseArgs(&opt, ll("--plain", "010"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Plain).Should(Equal(10))
+		})
+
+		It("Gives clear errors", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--mode", "0789"))
+			Ω(err).Should(MatchError("invalid base-8 integer `0789`"))
+			_, _, err = cli.ParseArgs(&opt, ll("--mask", "0x100"))
+			Ω(err).Should(MatchError("integer `0x100` is out of range"))
+			_, _, err = cli.ParseArgs(&opt, ll("--count", "0xzz"))
+			Ω(err).Should(MatchError("invalid integer `0xzz`"))
+			_, _, err = cli.ParseArgs(&opt, ll("--mask", "f_f"))
+			Ω(err).Should(MatchError("invalid base-16 integer `f_f`"))
+		})
+
+		It("Rejects bad base tags", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				N int `cli:"-n" base:"1"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid base tag '1' on field N (should be 0, or between 2 and 36)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				N int `cli:"-n" base:"hex"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid base tag 'hex' on field N (should be 0, or between 2 and 36)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				S string `cli:"-s" base:"16"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid base tag '16' on field S (only integers have a base)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				N int `cli:"-n" base:"16" unit:"bytes"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid base tag '16' on field N (byte sizes are always given in base 10)"))
+		})
+
+		It("Writes values back out in their base", func() {
+			opt := Options{Count: 42, Mode: 0755, Mask: 0xf0, Bits: []int8{5, -3, 0}}
+			args, err := cli.Marshal(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--count", "42", "--mode", "0755", "--mask", "0xf0",
+				"--bits", "0b101", "--bits", "-0b11", "--bits", "0b0")))
+
+			var out Options
+			_, _, err = cli.ParseArgs(&out, args)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(out).Should(Equal(opt))
+
+			md, err := cli.Markdown(&Options{Mode: 0644}, cli.MarkdownOptions{Name: "tool"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(md).Should(ContainSubstring("| `-m`, `--mode` | `N` | `0644` | Given in base 8. |"))
+			Ω(md).Should(ContainSubstring("May be given in hexadecimal (0x...), octal (0o...) or binary (0b...)."))
+
+			spec, err := cli.Spec(&Options{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec.Options[0].Base).Should(Equal("0"))
+			Ω(spec.Options[2].Base).Should(Equal("16"))
+			Ω(spec.Options[4].Base).Should(Equal(""))
+		})
+	})
+
+	// }}}
+	Describe("Indirect values", func() { // {{{
+		type Options struct {
+			Password string   `cli:"-p, --password" indirect:"true"`
+			Token    *string  `cli:"--token" indirect:"true"`
+			Keys     []string `cli:"-k, --key" indirect:"true" sep:","`
+			Name     string   `cli:"-n, --name"`
+		}
+
+		var (
+			dir   string
+			stdin *os.File
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "go-cli-test")
+			Ω(err).ShouldNot(HaveOccurred())
+			stdin = os.Stdin
+		})
+
+		AfterEach(func() {
+			os.Stdin = stdin
+			os.RemoveAll(dir)
+		})
+
+		file := func(name, contents string) string {
+			path := filepath.Join(dir, name)
+			Ω(ioutil.WriteFile(path, []byte(contents), 0644)).Should(Succeed())
+			return path
+		}
+
+		/* feed swaps out standard input for a file with the given contents */
+		feed := func(contents string) {
+			f, err := os.Open(file("stdin", contents))
+			Ω(err).ShouldNot(HaveOccurred())
+			os.Stdin = f
+		}
+
+		It("Reads values from files, trimming trailing newlines", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-p", "@"+file("pw", "hunter2\n\n"), "--token", "@"+file("token", " t0k3n \r\n")))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Password).Should(Equal("hunter2"))
+			Ω(*opt.Token).Should(Equal(" t0k3n "))
+		})
+
+		It("Reads values from standard input, but only once", func() {
+			feed("from stdin\n")
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("--password", "-"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Password).Should(Equal("from stdin"))
+
+			feed("again\n")
+			_, _, err = cli.ParseArgs(&opt, ll("--password", "-", "-k", "-"))
+			Ω(err).Should(MatchError("unable to read value for `-k` flag from standard input: it was already read for `--password`"))
+		})
+
+		It("Reads values from the environment, as-is", func() {
+			os.Setenv("GO_CLI_TEST_SECRET", " s3cr3t\n")
+			defer os.Unsetenv("GO_CLI_TEST_SECRET")
+
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-p", "env:GO_CLI_TEST_SECRET"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Password).Should(Equal(" s3cr3t\n"))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-p", "env:GO_CLI_TEST_NOPE"))
+			Ω(err).Should(MatchError("unable to read value for `-p` flag from `env:GO_CLI_TEST_NOPE`: no such environment variable"))
+		})
+
+		It("Splits delimited lists after reading them in", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-k", "@"+file("keys", "a,b\n"), "-k", "c"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Keys).Should(Equal(ll("a", "b", "c")))
+		})
+
+		It("Takes escaped values literally", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-p", `\@home`, "--token", `\-`, "-k", `\env:x`, "-k", `\\-`, "-k", `\x`))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Password).Should(Equal("@home"))
+			Ω(*opt.Token).Should(Equal("-"))
+			Ω(opt.Keys).Should(Equal(ll("env:x", `\-`, `\x`)))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-n", "@home"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Name).Should(Equal("@home"))
+		})
+
+		It("Enforces size limits", func() {
+			opt := Options{}
+			path := file("big", strings.Repeat("x", 100))
+			_, _, err := cli.ParseArgs(&opt, ll("-p", "@"+path), cli.IndirectLimit(64))
+			Ω(err).Should(MatchError("value for `-p` flag from `@" + path + "` is too big (the limit is 64B)"))
+
+			_, _, err = cli.ParseArgs(&opt, ll("-p", "@"+path), cli.IndirectLimit(100))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, _, err = cli.ParseArgs(&opt, ll("-p", "@"+file("huge", strings.Repeat("x", 64<<10+1))))
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(HaveSuffix("is too big (the limit is 64KiB)"))
+		})
+
+		It("Complains about files it can't read", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-p", "@"+filepath.Join(dir, "nope")))
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(HavePrefix("unable to read value for `-p` flag from `@" + filepath.Join(dir, "nope") + "`: "))
+		})
+
+		It("Rejects indirect tags on things that don't take values", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Debug bool `cli:"-D" indirect:"true"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid indirect tag on field Debug (only options that take values can be indirect)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Name string `cli:"-n" indirect:"sure"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid indirect tag 'sure' on field Name"))
+		})
+
+		It("Escapes values when marshaling", func() {
+			dash := "-"
+			opt := Options{Password: "@home", Token: &dash, Keys: ll("env:x", "y"), Name: "@name"}
+			args, err := cli.Marshal(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--password", `\@home`, "--token", `\-`, "--key", `\env:x,y`, "--name", "@name")))
+
+			var out Options
+			_, _, err = cli.ParseArgs(&out, args)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(out).Should(Equal(opt))
+		})
+	})
+
+	// }}}
+
+	Describe("Secret values", func() { // {{{
+		type Options struct {
+			Token  string   `cli:"-t, --token" secret:"true" help:"API token."`
+			PIN    int      `cli:"--pin" secret:"true"`
+			Keys   []string `cli:"-k, --key" secret:"true" sep:","`
+			Quota  uint8    `cli:"--quota" secret:"true" unit:"bytes"`
+			Name   string   `cli:"-n, --name"`
+			Vault  *string  `cli:"--vault" secret:"true" indirect:"true"`
+			Unsafe int      `cli:"--unsafe"`
+		}
+
+		It("Parses secret values like any other", func() {
+			opt := Options{}
+			_, _, err := cli.ParseArgs(&opt, ll("-t", "s3cr3t", "--pin", "1234", "-k", "a,b"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(opt.Token).Should(Equal("s3cr3t"))
+			Ω(opt.PIN).Should(Equal(1234))
+			Ω(opt.Keys).Should(Equal(ll("a", "b")))
+		})
+
+		It("Keeps secret values out of error messages", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("--pin", "hunter2"))
+			Ω(err).Should(MatchError("invalid value for `--pin` flag (it is secret, so it isn't shown)"))
+			Ω(err.Error()).ShouldNot(ContainSubstring("hunter2"))
+
+			_, _, err = cli.ParseArgs(&Options{}, ll("--quota", "1KiB"))
+			Ω(err).Should(MatchError("invalid value for `--quota` flag (it is secret, so it isn't shown)"))
+
+			/* non-secret values are still shown */
+			_, _, err = cli.ParseArgs(&Options{}, ll("--unsafe", "hunter2"))
+			Ω(err).Should(MatchError(`strconv.ParseInt: parsing "hunter2": invalid syntax`))
+		})
+
+		It("Keeps secret values out of errors about --name=value flags", func() {
+			_, _, err := cli.ParseArgs(&Options{}, ll("--token=hunter2"))
+			Ω(err).Should(MatchError("unrecognized flag `--token=<redacted>`"))
+			Ω(err.Error()).ShouldNot(ContainSubstring("hunter2"))
+
+			/* even if the secret option belongs to some other command */
+			_, _, err = cli.ParseArgs(&struct {
+				Login struct {
+					Password string `cli:"-p, --password" secret:"true"`
+				} `cli:"login"`
+			}{}, ll("--password=hunter2", "login"))
+			Ω(err).Should(MatchError("unrecognized flag `--password=<redacted>`"))
+
+			/* anything else is left as-is */
+			_, _, err = cli.ParseArgs(&Options{}, ll("--name=hunter2"))
+			Ω(err).Should(MatchError("unrecognized flag `--name=hunter2`"))
+		})
+
+		It("Keeps secret defaults out of the docs", func() {
+			opt := Options{Token: "d3fault", PIN: 9999, Name: "bob"}
+
+			page, err := cli.ManPage(&opt, cli.ManOptions{Name: "safe"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(page).ShouldNot(ContainSubstring("d3fault"))
+			Ω(page).ShouldNot(ContainSubstring("9999"))
+			Ω(page).Should(ContainSubstring("Defaults to bob."))
+
+			doc, err := cli.Markdown(&opt, cli.MarkdownOptions{Name: "safe"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(doc).Should(ContainSubstring("| `-t`, `--token` | `VALUE` |  | API token. |"))
+			Ω(doc).ShouldNot(ContainSubstring("d3fault"))
+
+			spec, err := cli.Spec(&opt)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(spec.Options[0].Secret).Should(BeTrue())
+			Ω(spec.Options[0].Default).Should(BeNil())
+			Ω(spec.Options[4].Secret).Should(BeFalse())
+			Ω(spec.Options[4].Default).Should(Equal("bob"))
+
+			b, err := spec.JSONSchema()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(b)).ShouldNot(ContainSubstring("d3fault"))
+			Ω(string(b)).Should(ContainSubstring(`"writeOnly": true`))
+		})
+
+		It("Redacts secret values when marshaling, unless asked not to", func() {
+			vault := "@v"
+			opt := Options{Token: "s3cr3t", Keys: ll("a", "b"), Name: "bob", Vault: &vault}
+			args, err := cli.Marshal(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--token", "<redacted>", "--key", "<redacted>", "--name", "bob", "--vault", "<redacted>")))
+
+			args, err = cli.MarshalWith(&opt, "", cli.MarshalOptions{Secrets: true})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(args).Should(Equal(ll("--token", "s3cr3t", "--key", "a,b", "--name", "bob", "--vault", `\@v`)))
+
+			var out Options
+			_, _, err = cli.ParseArgs(&out, args)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(out).Should(Equal(opt))
+		})
+
+		It("Keeps secret values out of flag.FlagSets", func() {
+			opt := Options{Token: "d3fault"}
+			fs, err := cli.FlagSet(&opt, "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fs.Lookup("token").DefValue).Should(Equal(""))
+			Ω(fs.Lookup("token").Value.String()).Should(Equal(""))
+
+			Ω(fs.Set("pin", "hunter2")).Should(MatchError("invalid value for `--pin` flag (it is secret, so it isn't shown)"))
+			Ω(fs.Set("t", "s3cr3t")).Should(Succeed())
+			Ω(opt.Token).Should(Equal("s3cr3t"))
+		})
+
+		It("Rejects secret tags on things that don't take values", func() {
+			_, _, err := cli.ParseArgs(&struct {
+				Debug bool `cli:"-D" secret:"true"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid secret tag on field Debug (only options that take values can be secret)"))
+
+			_, _, err = cli.ParseArgs(&struct {
+				Name string `cli:"-n" secret:"shh"`
+			}{}, ll())
+			Ω(err).Should(MatchError("invalid secret tag 'shh' on field Name"))
+		})
+	})
+
+	// }}}
+
+	Describe("Dumping options", func() { // {{{
+		type Options struct {
+			Debug bool    `cli:"-D, --debug"`
+			Name  string  `cli:"-n, --name"`
+			Token string  `cli:"--token" secret:"true" indirect:"true"`
+			Home  *string `cli:"--home"`
+
+			Build struct {
+				Cores int      `cli:"-c, --cores"`
+				Tags  []string `cli:"-t, --tag"`
+			} `cli:"build, b"`
+		}
+
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "go-cli-test")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		dump := func(p *cli.Parser, format cli.DumpFormat) string {
+			var b bytes.Buffer
+			Ω(p.Dump(&b, format)).Should(Succeed())
+			return b.String()
+		}
+
+		It("Dumps the global options before the first command", func() {
+			opt := Options{Name: "bob"}
+			p, err := cli.NewParser(&opt, ll("-D", "build", "-c", "4"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(dump(p, cli.DumpText)).Should(Equal(
+				"COMMAND      FLAG     VALUE       ORIGIN\n" +
+					"(top-level)  --debug  true        argument 1\n" +
+					"(top-level)  --name   bob         default\n" +
+					"(top-level)  --token  <redacted>  default\n" +
+					"(top-level)  --home   (unset)     default\n"))
+		})
+
+		It("Dumps what each chained command saw, and where it came from", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("-n", "one two", "b", "-c", "4", "-t", "x", "-t", "y", "--", "build", "--home", "/root"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(p.Next()).Should(BeTrue())
+			Ω(dump(p, cli.DumpText)).Should(Equal(
+				"COMMAND      FLAG     VALUE       ORIGIN\n" +
+					"(top-level)  --debug  false       default\n" +
+					"(top-level)  --name   'one two'   argument 1\n" +
+					"(top-level)  --token  <redacted>  default\n" +
+					"(top-level)  --home   (unset)     default\n" +
+					"build        --cores  4           argument 4\n" +
+					"build        --tag    x y         argument 8\n"))
+
+			/* everything goes back the way it was, in between */
+			Ω(p.Next()).Should(BeTrue())
+			Ω(dump(p, cli.DumpText)).Should(Equal(
+				"COMMAND      FLAG     VALUE       ORIGIN\n" +
+					"(top-level)  --debug  false       default\n" +
+					"(top-level)  --name   'one two'   argument 1\n" +
+					"(top-level)  --token  <redacted>  default\n" +
+					"(top-level)  --home   /root       argument 12\n" +
+					"build        --cores  0           default\n" +
+					"build        --tag    (empty)     default\n"))
+			Ω(p.Next()).Should(BeFalse())
+			Ω(p.Error()).ShouldNot(HaveOccurred())
+		})
+
+		It("Dumps as JSON and YAML", func() {
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("build", "-t", "x", "-D"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Next()).Should(BeTrue())
+
+			var out struct {
+				Command string
+				Options []map[string]interface{}
+			}
+			Ω(json.Unmarshal([]byte(dump(p, cli.DumpJSON)), &out)).Should(Succeed())
+			Ω(out.Command).Should(Equal("build"))
+			Ω(out.Options).Should(HaveLen(6))
+			Ω(out.Options[0]).Should(Equal(map[string]interface{}{
+				"command": "",
+				"flag":    "--debug",
+				"field":   "Debug",
+				"value":   "true",
+				"origin":  map[string]interface{}{"source": "argv", "arg": 4.0},
+			}))
+			Ω(out.Options[2]["value"]).Should(Equal("<redacted>"))
+			Ω(out.Options[3]["value"]).Should(BeNil())
+			Ω(out.Options[5]).Should(Equal(map[string]interface{}{
+				"command": "build",
+				"flag":    "--tag",
+				"field":   "Tags",
+				"value":   []interface{}{"x"},
+				"origin":  map[string]interface{}{"source": "argv", "arg": 2.0},
+			}))
+
+			y := dump(p, cli.DumpYAML)
+			Ω(y).Should(HavePrefix("command: build\noptions:\n- command: \"\"\n  flag: --debug\n"))
+			Ω(y).Should(ContainSubstring("  flag: --tag\n  field: Tags\n  value:\n  - x\n  origin:\n    source: argv\n    arg: 2\n"))
+		})
+
+		It("Tracks values from response files and indirect values", func() {
+			tok := filepath.Join(dir, "token")
+			Ω(ioutil.WriteFile(tok, []byte("s3cr3t\n"), 0644)).Should(Succeed())
+			args := filepath.Join(dir, "build.args")
+			Ω(ioutil.WriteFile(args, []byte("# build it\nbuild\n  --cores 8\n"), 0644)).Should(Succeed())
+			os.Setenv("GO_CLI_TEST_HOME", "/home/bob")
+			defer os.Unsetenv("GO_CLI_TEST_HOME")
+
+			opt := Options{}
+			p, err := cli.NewParser(&opt, ll("--token", "@@"+tok, "@"+args), cli.ResponseFiles())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Next()).Should(BeTrue())
+			Ω(opt.Token).Should(Equal("s3cr3t"))
+
+			s := dump(p, cli.DumpText)
+			Ω(s).Should(ContainSubstring(fmt.Sprintf("--token  <redacted>  %s (per argument 1)\n", tok)))
+			Ω(s).Should(ContainSubstring(fmt.Sprintf("--cores  8           %s:3 (from argument 3)\n", args)))
+			Ω(s).ShouldNot(ContainSubstring("s3cr3t"))
+
+			type Env struct {
+				Token string `cli:"--token" indirect:"true"`
+			}
+			p, err = cli.NewParser(&Env{}, ll("--token", "env:GO_CLI_TEST_HOME"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(dump(p, cli.DumpText)).Should(ContainSubstring("--token  /home/bob  $GO_CLI_TEST_HOME (per argument 1)\n"))
+		})
+
+		It("Rejects formats it doesn't know", func() {
+			p, err := cli.NewParser(&Options{}, ll())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.Dump(ioutil.Discard, "xml")).Should(MatchError("unrecognized dump format `xml`"))
+		})
+	})
+
 	// }}}
 })
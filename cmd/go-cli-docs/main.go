@@ -0,0 +1,103 @@
+/* go-cli-docs generates Markdown reference documentation for a go-cli
+   options structure, straight from the Go source that defines it.  It
+   is meant to be run from `go generate`:
+
+     //go:generate go run github.com/jhunt/go-cli/cmd/go-cli-docs -t Options -o docs/cli.md
+
+   Since the program is never actually run, only the structure (tags,
+   help text, aliases, etc.) is documented; default values assigned at
+   run-time are not visible to go-cli-docs. */
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jhunt/go-cli"
+	"github.com/jhunt/go-cli/internal/source"
+)
+
+type Options struct {
+	Help    bool   `cli:"-h, --help" help:"Show this help."`
+	Package string `cli:"-p, --package" help:"Directory or import path of the package to document (defaults to the current directory)."`
+	Type    string `cli:"-t, --type" help:"Name of the options structure type."`
+	Name    string `cli:"-n, --name" help:"Name of the program (defaults to the package directory name)."`
+	Version string `cli:"-v, --version" help:"Version of the program."`
+	Summary string `cli:"-s, --summary" help:"One-line description of the program."`
+	Output  string `cli:"-o, --output" help:"File to write the documentation to, or - for standard output (the default)."`
+	Split   string `cli:"-d, --split" help:"Write one file per sub-command into this directory, instead."`
+}
+
+func main() {
+	opts := Options{Package: "."}
+	_, args, err := cli.Parse(&opts)
+	if err != nil {
+		bail("%s", err)
+	}
+	if opts.Help {
+		fmt.Printf("USAGE: go-cli-docs -t TYPE [-p PACKAGE] [-o FILE | -d DIR]\n\n")
+		doc, _ := cli.Markdown(&Options{}, cli.MarkdownOptions{Name: "go-cli-docs"})
+		fmt.Printf("%s\n", doc)
+		os.Exit(0)
+	}
+	if len(args) > 0 {
+		bail("unexpected arguments: %s", cli.Quote(args))
+	}
+	if opts.Type == "" {
+		bail("missing required --type flag")
+	}
+
+	dir, err := source.Locate(opts.Package)
+	if err != nil {
+		bail("%s", err)
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(dir)
+	}
+
+	thing, _, _, err := source.Rebuild(dir, opts.Type)
+	if err != nil {
+		bail("%s", err)
+	}
+
+	md := cli.MarkdownOptions{
+		Name:    opts.Name,
+		Version: opts.Version,
+		Summary: opts.Summary,
+	}
+
+	if opts.Split != "" {
+		files, err := cli.MarkdownFiles(thing, md)
+		if err != nil {
+			bail("%s", err)
+		}
+		if err := os.MkdirAll(opts.Split, 0777); err != nil {
+			bail("%s", err)
+		}
+		for name, doc := range files {
+			if err := ioutil.WriteFile(filepath.Join(opts.Split, name), []byte(doc+"\n"), 0666); err != nil {
+				bail("%s", err)
+			}
+		}
+		return
+	}
+
+	doc, err := cli.Markdown(thing, md)
+	if err != nil {
+		bail("%s", err)
+	}
+	if opts.Output == "" || opts.Output == "-" {
+		fmt.Printf("%s\n", doc)
+		return
+	}
+	if err := ioutil.WriteFile(opts.Output, []byte(doc+"\n"), 0666); err != nil {
+		bail("%s", err)
+	}
+}
+
+func bail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "go-cli-docs: "+format+"\n", args...)
+	os.Exit(1)
+}
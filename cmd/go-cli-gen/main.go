@@ -0,0 +1,821 @@
+/* go-cli-gen generates a type-specific, reflection-free parser for a
+   go-cli options structure, straight from the Go source that defines
+   it.  It is meant to be run from `go generate`:
+
+     //go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Options
+
+   For a type named Options, the generated code provides:
+
+     NewOptionsParser(opts *Options, args []string) (*OptionsParser, error)
+     ParseOptionsArgs(opts *Options, args []string) (string, []string, error)
+
+   which behave exactly like cli.NewParser() and cli.ParseArgs(), right
+   down to the error messages and deprecation warnings, but only for
+   what go-cli does without being asked:
+
+     - None of the settings can be given, so there's no cli.WarnTo(),
+       cli.ResponseFiles(), cli.StrictOrder(), cli.NegativeNumbers(),
+       cli.SingleDashLongs(), cli.MountFlags(), cli.IndirectLimit()
+       or cli.WithSignals().
+
+     - Structures with options tagged `unit` (or declared as a
+       cli.ByteSize or cli.Percent), `base` (other than 10) or
+       `indirect` are refused outright.  Options tagged `secret`
+       are fine.
+
+     - The generated parsers only have Next() and Error(); there's
+       no Run() or Dump(). */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jhunt/go-cli"
+	"github.com/jhunt/go-cli/internal/source"
+)
+
+type Options struct {
+	Help    bool   `cli:"-h, --help" help:"Show this help."`
+	Package string `cli:"-p, --package" help:"Directory or import path of the package to generate for (defaults to the current directory)."`
+	Type    string `cli:"-t, --type" help:"Name of the options structure type."`
+	Output  string `cli:"-o, --output" help:"File to write the parser to (defaults to TYPE_cli.go, lowercased, in the package directory)."`
+}
+
+func main() {
+	opts := Options{Package: "."}
+	_, args, err := cli.Parse(&opts)
+	if err != nil {
+		bail("%s", err)
+	}
+	if opts.Help {
+		fmt.Printf("USAGE: go-cli-gen -t TYPE [-p PACKAGE] [-o FILE]\n\n")
+		doc, _ := cli.Markdown(&Options{}, cli.MarkdownOptions{Name: "go-cli-gen"})
+		fmt.Printf("%s\n", doc)
+		os.Exit(0)
+	}
+	if len(args) > 0 {
+		bail("unexpected arguments: %s", cli.Quote(args))
+	}
+	if opts.Type == "" {
+		bail("missing required --type flag")
+	}
+
+	dir, err := source.Locate(opts.Package)
+	if err != nil {
+		bail("%s", err)
+	}
+	thing, pkg, named, err := source.Rebuild(dir, opts.Type)
+	if err != nil {
+		bail("%s", err)
+	}
+	spec, err := cli.Spec(thing)
+	if err != nil {
+		bail("%s", err)
+	}
+	rename(spec, "", named)
+
+	code, err := generate(pkg, opts.Type, spec)
+	if err != nil {
+		bail("%s", err)
+	}
+
+	if opts.Output == "" {
+		opts.Output = filepath.Join(dir, strings.ToLower(opts.Type)+"_cli.go")
+	}
+	if opts.Output == "-" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := ioutil.WriteFile(opts.Output, code, 0666); err != nil {
+		bail("%s", err)
+	}
+}
+
+func bail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "go-cli-gen: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+/* rename puts the names of the named types that options were declared
+   with (which reflecting on the rebuilt structure can't see) back into
+   the spec, so that the generated code uses them. */
+func rename(c *cli.CommandSpec, path string, named map[string]string) {
+	for _, o := range c.Options {
+		if name, ok := named[path+o.Field]; ok {
+			o.Type = name
+		}
+	}
+	for _, sub := range c.Commands {
+		rename(sub, path+sub.Field+".", named)
+	}
+}
+
+/* level is a sub-command (or the top-level program), numbered in
+   the order they are found; the top-level is always level 0. */
+type level struct {
+	spec  *cli.CommandSpec
+	dflt  int
+	flags []int
+	subs  map[string]int
+}
+
+/* flag is an option, numbered in the order they are found, along
+   with the Go expression (relative to the parser) that gets at it. */
+type flag struct {
+	spec *cli.OptionSpec
+	path string
+}
+
+type grammar struct {
+	levels []*level
+	flags  []*flag
+}
+
+/* walk numbers the sub-commands and options, depth-first. */
+func (g *grammar) walk(c *cli.CommandSpec, path string) int {
+	id := len(g.levels)
+	lvl := &level{spec: c, dflt: -1, flags: []int{}, subs: map[string]int{}}
+	g.levels = append(g.levels, lvl)
+
+	for _, o := range c.Options {
+		lvl.flags = append(lvl.flags, len(g.flags))
+		g.flags = append(g.flags, &flag{spec: o, path: path + "." + o.Field})
+	}
+	for _, sub := range c.Commands {
+		n := g.walk(sub, path+"."+sub.Field)
+		lvl.subs[sub.Name] = n
+		for _, alias := range sub.Aliases {
+			lvl.subs[alias] = n
+		}
+		if sub.Name == c.Default {
+			lvl.dflt = n
+		}
+	}
+	return id
+}
+
+func generate(pkg, typ string, spec *cli.CommandSpec) ([]byte, error) {
+	g := &grammar{}
+	g.walk(spec, "p.opts")
+
+	var b bytes.Buffer
+	numeric := false
+	for _, f := range g.flags {
+		if _, err := convert(f.spec); err != nil {
+			return nil, fmt.Errorf("unable to generate a parser for the `%s` option: %s", f.path[len("p.opts."):], err)
+		}
+		if f.spec.Kind != "string" && f.spec.Kind != "bool" {
+			numeric = true
+		}
+	}
+
+	fmt.Fprintf(&b, "// Code generated by go-cli-gen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import (\n\t\"fmt\"\n")
+	if numeric {
+		fmt.Fprintf(&b, "\t\"strconv\"\n")
+	}
+	fmt.Fprintf(&b, "\t\"strings\"\n)\n\n")
+
+	names := strings.NewReplacer(
+		"TYPE", typ,
+		"PARSER", typ+"Parser",
+		"LEVELS", lower(typ)+"Levels",
+		"FLAGS", lower(typ)+"Flags",
+		"NFLAGS", strconv.Itoa(len(g.flags)))
+	b.WriteString(names.Replace(engine))
+
+	fmt.Fprintf(&b, "\nvar %sLevels = []struct {\n", lower(typ))
+	fmt.Fprintf(&b, "\tcommand    string\n")
+	fmt.Fprintf(&b, "\tstop       bool\n")
+	fmt.Fprintf(&b, "\tdflt       int /* level of the default sub-command, or -1 */\n")
+	fmt.Fprintf(&b, "\tdeprecated bool\n")
+	fmt.Fprintf(&b, "\tadvice     string\n")
+	fmt.Fprintf(&b, "\tflags      []int\n")
+	fmt.Fprintf(&b, "\tsubs       map[string]int\n")
+	fmt.Fprintf(&b, "}{\n")
+	for _, lvl := range g.levels {
+		fmt.Fprintf(&b, "\t{\n")
+		fmt.Fprintf(&b, "\t\tcommand: %q,\n", lvl.spec.Name)
+		if lvl.spec.Stop {
+			fmt.Fprintf(&b, "\t\tstop: true,\n")
+		}
+		fmt.Fprintf(&b, "\t\tdflt: %d,\n", lvl.dflt)
+		if lvl.spec.Deprecated != nil {
+			fmt.Fprintf(&b, "\t\tdeprecated: true,\n")
+			fmt.Fprintf(&b, "\t\tadvice: %q,\n", *lvl.spec.Deprecated)
+		}
+		fmt.Fprintf(&b, "\t\tflags: %s,\n", ints(lvl.flags))
+		fmt.Fprintf(&b, "\t\tsubs: map[string]int{")
+		subs := make([]string, 0, len(lvl.subs))
+		for name := range lvl.subs {
+			subs = append(subs, name)
+		}
+		sort.Strings(subs)
+		for i, name := range subs {
+			if i > 0 {
+				fmt.Fprintf(&b, ", ")
+			}
+			fmt.Fprintf(&b, "%q: %d", name, lvl.subs[name])
+		}
+		fmt.Fprintf(&b, "},\n")
+		fmt.Fprintf(&b, "\t},\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	fmt.Fprintf(&b, "\nvar %sFlags = []struct {\n", lower(typ))
+	fmt.Fprintf(&b, "\tshorts     string\n")
+	fmt.Fprintf(&b, "\tlongs      []string\n")
+	fmt.Fprintf(&b, "\tnegations  []string /* take no value, and clear the option */\n")
+	fmt.Fprintf(&b, "\ttoggle     bool /* takes no value */\n")
+	fmt.Fprintf(&b, "\tdeprecated bool\n")
+	fmt.Fprintf(&b, "\tadvice     string\n")
+	fmt.Fprintf(&b, "\tsecret     bool /* values are never shown */\n")
+	fmt.Fprintf(&b, "}{\n")
+	for _, f := range g.flags {
+		fmt.Fprintf(&b, "\t{\n")
+		fmt.Fprintf(&b, "\t\tshorts: %q,\n", strings.Join(f.spec.Shorts, ""))
+		fmt.Fprintf(&b, "\t\tlongs: %s,\n", strs(f.spec.Longs))
+		if len(f.spec.Negations) > 0 {
+			fmt.Fprintf(&b, "\t\tnegations: %s,\n", strs(f.spec.Negations))
+		}
+		if !f.spec.Value {
+			fmt.Fprintf(&b, "\t\ttoggle: true,\n")
+		}
+		if f.spec.Deprecated != nil {
+			fmt.Fprintf(&b, "\t\tdeprecated: true,\n")
+			fmt.Fprintf(&b, "\t\tadvice: %q,\n", *f.spec.Deprecated)
+		}
+		if f.spec.Secret {
+			fmt.Fprintf(&b, "\t\tsecret: true,\n")
+		}
+		fmt.Fprintf(&b, "\t},\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	/* bind: note which pointers are already set */
+	fmt.Fprintf(&b, "\nfunc (p *%sParser) bind() {\n", typ)
+	for i, f := range g.flags {
+		if f.spec.Nullable {
+			fmt.Fprintf(&b, "\tp.bound[%d] = %s != nil\n", i, f.path)
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	/* save: deep-copy the options, so that revert has something to go back to */
+	fmt.Fprintf(&b, "\nfunc (p *%sParser) save() {\n", typ)
+	fmt.Fprintf(&b, "\tp.saved = *p.opts\n")
+	fmt.Fprintf(&b, "\tp.reinit = p.init\n")
+	for _, f := range g.flags {
+		saved := "p.saved" + f.path[len("p.opts"):]
+		switch {
+		case f.spec.Repeatable:
+			fmt.Fprintf(&b, "\tif %s != nil {\n\t\t%s = append([]%s{}, %s...)\n\t}\n", f.path, saved, gotype(f.spec), f.path)
+		case f.spec.Nullable:
+			fmt.Fprintf(&b, "\tif %s != nil {\n\t\tv := *%s\n\t\t%s = &v\n\t}\n", f.path, f.path, saved)
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	/* revert: put the options on one level back the way they were after the globals */
+	fmt.Fprintf(&b, "\nfunc (p *%sParser) revert(lvl int) {\n", typ)
+	fmt.Fprintf(&b, "\tswitch lvl {\n")
+	for n, lvl := range g.levels {
+		if len(lvl.flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\tcase %d:\n", n)
+		for _, i := range lvl.flags {
+			f := g.flags[i]
+			saved := "p.saved" + f.path[len("p.opts"):]
+			switch {
+			case f.spec.Repeatable:
+				fmt.Fprintf(&b, "\t\tp.init[%d] = p.reinit[%d]\n", i, i)
+				fmt.Fprintf(&b, "\t\tif %s == nil {\n\t\t\t%s = nil\n\t\t} else {\n\t\t\t%s = append([]%s{}, %s...)\n\t\t}\n",
+					saved, f.path, f.path, gotype(f.spec), saved)
+			case f.spec.Nullable:
+				fmt.Fprintf(&b, "\t\tif p.bound[%d] {\n\t\t\t*%s = *%s\n\t\t} else if %s == nil {\n\t\t\t%s = nil\n\t\t} else {\n\t\t\tv := *%s\n\t\t\t%s = &v\n\t\t}\n",
+					i, f.path, saved, saved, f.path, saved, f.path)
+			default:
+				fmt.Fprintf(&b, "\t\t%s = %s\n", f.path, saved)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\t}\n}\n")
+
+	/* enable: turn boolean options on (or off) */
+	fmt.Fprintf(&b, "\nfunc (p *%sParser) enable(flag int, on bool) {\n", typ)
+	fmt.Fprintf(&b, "\tswitch flag {\n")
+	for i, f := range g.flags {
+		if f.spec.Value {
+			continue
+		}
+		fmt.Fprintf(&b, "\tcase %d:\n", i)
+		on := "on"
+		if f.spec.Type != "" {
+			fmt.Fprintf(&b, "\t\tv := %s(on)\n", f.spec.Type)
+			on = "v"
+		}
+		if f.spec.Nullable {
+			fmt.Fprintf(&b, "\t\tif p.bound[%d] {\n\t\t\t*%s = %s\n\t\t} else {\n\t\t\t%s = &%s\n\t\t}\n", i, f.path, on, f.path, on)
+		} else {
+			fmt.Fprintf(&b, "\t\t%s = %s\n", f.path, on)
+		}
+	}
+	fmt.Fprintf(&b, "\t}\n}\n")
+
+	/* negate: clear out negatable options */
+	fmt.Fprintf(&b, "\nfunc (p *%sParser) negate(flag int) {\n", typ)
+	fmt.Fprintf(&b, "\tswitch flag {\n")
+	for i, f := range g.flags {
+		if len(f.spec.Negations) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\tcase %d:\n", i)
+		switch {
+		case f.spec.Repeatable:
+			fmt.Fprintf(&b, "\t\tp.init[%d] = true\n\t\t%s = []%s{}\n", i, f.path, gotype(f.spec))
+		case f.spec.Nullable:
+			fmt.Fprintf(&b, "\t\tif p.bound[%d] {\n\t\t\tvar zero %s\n\t\t\t*%s = zero\n\t\t} else {\n\t\t\t%s = nil\n\t\t}\n",
+				i, gotype(f.spec), f.path, f.path)
+		default:
+			fmt.Fprintf(&b, "\t\tvar zero %s\n\t\t%s = zero\n", gotype(f.spec), f.path)
+		}
+	}
+	fmt.Fprintf(&b, "\t}\n}\n")
+
+	/* set: convert and store values */
+	fmt.Fprintf(&b, "\nfunc (p *%sParser) set(flag int, raw string) error {\n", typ)
+	fmt.Fprintf(&b, "\tswitch flag {\n")
+	for i, f := range g.flags {
+		if !f.spec.Value {
+			continue
+		}
+		conv, _ := convert(f.spec)
+		fmt.Fprintf(&b, "\tcase %d:\n", i)
+		switch {
+		case f.spec.Repeatable && f.spec.Separator != "":
+			fmt.Fprintf(&b, "\t\tl := []%s{}\n\t\tfor _, raw := range %sSplit(raw, %q) {\n%s\t\t\tl = append(l, v)\n\t\t}\n",
+				gotype(f.spec), lower(typ), f.spec.Separator, conv)
+			fmt.Fprintf(&b, "\t\tif !p.init[%d] {\n\t\t\tp.init[%d] = true\n\t\t\t%s = l\n\t\t} else {\n\t\t\t%s = append(%s, l...)\n\t\t}\n",
+				i, i, f.path, f.path, f.path)
+		case f.spec.Repeatable:
+			fmt.Fprintf(&b, "%s", conv)
+			fmt.Fprintf(&b, "\t\tif !p.init[%d] {\n\t\t\tp.init[%d] = true\n\t\t\t%s = []%s{v}\n\t\t} else {\n\t\t\t%s = append(%s, v)\n\t\t}\n",
+				i, i, f.path, gotype(f.spec), f.path, f.path)
+		case f.spec.Nullable:
+			fmt.Fprintf(&b, "%s", conv)
+			fmt.Fprintf(&b, "\t\tif p.bound[%d] {\n\t\t\t*%s = v\n\t\t} else {\n\t\t\t%s = &v\n\t\t}\n", i, f.path, f.path)
+		default:
+			fmt.Fprintf(&b, "%s", conv)
+			fmt.Fprintf(&b, "\t\t%s = v\n", f.path)
+		}
+	}
+	fmt.Fprintf(&b, "\t}\n\treturn nil\n}\n")
+
+	/* split: for delimited lists, if there are any */
+	for _, f := range g.flags {
+		if f.spec.Separator != "" {
+			b.WriteString(strings.Replace(splitter, "SPLIT", lower(typ)+"Split", -1))
+			break
+		}
+	}
+
+	code, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is malformed (this is a bug in go-cli-gen): %s", err)
+	}
+	return code, nil
+}
+
+/* convert writes the code that turns `raw` into a value `v`,
+   of the type the option holds, the same way go-cli would. */
+func convert(o *cli.OptionSpec) (string, error) {
+	if !o.Value {
+		return "", nil
+	}
+	if o.Unit != "" {
+		return "", fmt.Errorf("values with units (like cli.ByteSize) are not supported")
+	}
+	if o.Base != "" {
+		return "", fmt.Errorf("integers in base %s are not supported", o.Base)
+	}
+	if o.Indirect {
+		return "", fmt.Errorf("indirect values are not supported")
+	}
+
+	bits := map[string]string{
+		"int": "0", "int8": "8", "int16": "16", "int32": "32", "int64": "64",
+		"uint": "0", "uint8": "8", "uint16": "16", "uint32": "32", "uint64": "64",
+		"float32": "32", "float64": "64",
+	}
+	switch o.Kind {
+	case "string":
+		if o.Type != "" {
+			return fmt.Sprintf("\t\tv := %s(raw)\n", o.Type), nil
+		}
+		return "\t\tv := raw\n", nil
+
+	case "int", "int8", "int16", "int32", "int64":
+		return fmt.Sprintf("\t\tn, err := strconv.ParseInt(raw, 10, %s)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\t\tv := %s(n)\n",
+			bits[o.Kind], gotype(o)), nil
+
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return fmt.Sprintf("\t\tn, err := strconv.ParseUint(raw, 10, %s)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\t\tv := %s(n)\n",
+			bits[o.Kind], gotype(o)), nil
+
+	case "float32", "float64":
+		return fmt.Sprintf("\t\tn, err := strconv.ParseFloat(raw, %s)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\t\tv := %s(n)\n",
+			bits[o.Kind], gotype(o)), nil
+	}
+	return "", fmt.Errorf("lists of %s values are not supported", o.Kind)
+}
+
+/* gotype gives the Go type of an option's value (or of its list
+   elements): the named type it was declared with, if any, or else
+   the basic type for its kind. */
+func gotype(o *cli.OptionSpec) string {
+	if o.Type != "" {
+		return o.Type
+	}
+	return o.Kind
+}
+
+func lower(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func ints(l []int) string {
+	s := make([]string, len(l))
+	for i, n := range l {
+		s[i] = strconv.Itoa(n)
+	}
+	return "[]int{" + strings.Join(s, ", ") + "}"
+}
+
+func strs(l []string) string {
+	s := make([]string, len(l))
+	for i, v := range l {
+		s[i] = strconv.Quote(v)
+	}
+	return "[]string{" + strings.Join(s, ", ") + "}"
+}
+
+/* splitter breaks delimited list values up, exactly like go-cli does. */
+const splitter = `
+// SPLIT breaks a delimited list value up into its elements, honoring
+// backslash escapes of the separator (and of backslashes).
+func SPLIT(raw, sep string) []string {
+	l := make([]string, 0)
+	if raw == "" {
+		return l
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(raw); {
+		if raw[i] == '\\' && strings.HasPrefix(raw[i+1:], sep) {
+			b.WriteString(sep)
+			i += 1 + len(sep)
+			continue
+		}
+		if raw[i] == '\\' && strings.HasPrefix(raw[i+1:], "\\") {
+			b.WriteByte('\\')
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(raw[i:], sep) {
+			l = append(l, b.String())
+			b.Reset()
+			i += len(sep)
+			continue
+		}
+		b.WriteByte(raw[i])
+		i++
+	}
+	return append(l, b.String())
+}
+`
+
+/* engine is the fixed part of every generated parser; it mirrors the
+   parse() and Next() logic in go-cli itself, line for line, except
+   that it works off of the generated tables instead of reflection. */
+const engine = `// PARSER parses command-line arguments into a TYPE structure,
+// exactly as a cli.Parser would, but without any reflection.
+type PARSER struct {
+	Command  string
+	Args     []string
+	Warnings []string
+
+	opts   *TYPE
+	saved  TYPE
+	bound  [NFLAGS]bool /* pointers that were already set, to write through */
+	init   [NFLAGS]bool /* lists that have been started over */
+	reinit [NFLAGS]bool /* what to restore init to, between commands */
+	err    error
+	rest   []string
+	path   []int /* sub-command path of the last Next() */
+	ran    bool
+}
+
+/* NewPARSER is the TYPE-specific equivalent of cli.NewParser(). */
+func NewPARSER(opts *TYPE, args []string) (*PARSER, error) {
+	p := &PARSER{
+		opts:     opts,
+		Command:  "",
+		Args:     []string{},
+		Warnings: []string{},
+	}
+	p.bind()
+
+	/* parse the globals, but stop at the first non-option */
+	var (
+		unknown bool
+		err     error
+	)
+	if p.rest, unknown, err = p.parse(nil, args); err != nil {
+		/* leave flags we don't know to the default sub-command */
+		if !unknown || LEVELS[0].dflt < 0 {
+			return nil, err
+		}
+	}
+
+	/* save the globally-specified globals, to revert to */
+	p.save()
+	return p, nil
+}
+
+/* ParseTYPEArgs is the TYPE-specific equivalent of cli.ParseArgs(). */
+func ParseTYPEArgs(opts *TYPE, args []string) (string, []string, error) {
+	p, err := NewPARSER(opts, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(p.rest) == 0 && LEVELS[0].dflt < 0 {
+		return p.Command, p.Args, p.Error()
+	}
+
+	/* a leading separator ends the options, unless there's a
+	   default sub-command to fall into, the way Next() would */
+	if len(p.rest) > 0 && p.rest[0] == "--" && LEVELS[0].dflt < 0 {
+		return p.Command, append(p.Args, p.rest[1:]...), p.Error()
+	}
+
+	p.Next()
+	return p.Command, append(p.Args, p.rest...), p.Error()
+}
+
+func (p *PARSER) Error() error {
+	return p.err
+}
+
+func (p *PARSER) warn(what, advice string) {
+	if advice == "" {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s is deprecated", what))
+	} else {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s is deprecated; %s", what, advice))
+	}
+}
+
+/* hush keeps the value of a secret option out of the error
+   (if any) that came from setting it, via the given flag. */
+func (p *PARSER) hush(flag int, given string, err error) error {
+	if err == nil || !FLAGS[flag].secret {
+		return err
+	}
+	return fmt.Errorf("invalid value for ` + "`%s`" + ` flag (it is secret, so it isn't shown)", given)
+}
+
+/* secret tells if a long option name belongs to a secret option. */
+func (p *PARSER) secret(long string) bool {
+	for _, flag := range FLAGS {
+		if !flag.secret {
+			continue
+		}
+		for _, l := range flag.longs {
+			if l == long {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *PARSER) Next() bool {
+	/* a default sub-command gets one shot at running
+	   even if we were only given global options */
+	if len(p.rest) == 0 && (p.ran || LEVELS[0].dflt < 0) {
+		return false
+	}
+	p.ran = true
+
+	/* skip the chain separator */
+	if len(p.rest) > 0 && p.rest[0] == "--" {
+		p.rest = p.rest[1:]
+	}
+
+	/* revert the global state, and whatever
+	   the last command may have changed */
+	p.revert(0)
+	for _, lvl := range p.path {
+		p.revert(lvl)
+	}
+
+	var (
+		unknown bool
+		err     error
+	)
+	rest := p.rest     // contains the rest of the unparsed options
+	args := []string{} // positional arguments
+	cmd := []int{}     // sub-command stack
+	lvl := 0           // where we are in the sub-command depth
+	for {
+		if LEVELS[lvl].stop {
+			args = append(args, rest...)
+			rest = []string{}
+			break
+		}
+
+		if rest, unknown, err = p.parse(cmd, rest); err != nil {
+			/* flags we don't know might belong to the default
+			   sub-command, if we haven't committed to this one */
+			if unknown && len(args) == 0 && LEVELS[lvl].dflt >= 0 {
+				lvl = LEVELS[lvl].dflt
+				cmd = append(cmd, lvl)
+				continue
+			}
+			p.path = cmd
+			p.err = err
+			return false
+		}
+
+		if len(rest) == 0 || rest[0] == "--" {
+			/* out of options; fall into the default sub-command,
+			   if we haven't seen any positional arguments yet */
+			if len(args) == 0 && LEVELS[lvl].dflt >= 0 {
+				lvl = LEVELS[lvl].dflt
+				cmd = append(cmd, lvl)
+				continue
+			}
+
+			if len(rest) > 0 {
+				rest = rest[1:]
+			}
+			break
+		}
+
+		if len(args) != 0 {
+			args = append(args, rest[0])
+
+		} else if sub, ok := LEVELS[lvl].subs[rest[0]]; ok {
+			lvl = sub
+			cmd = append(cmd, lvl)
+			if LEVELS[lvl].deprecated {
+				p.warn(fmt.Sprintf("sub-command ` + "`%s`" + `", rest[0]), LEVELS[lvl].advice)
+			}
+
+		} else if LEVELS[lvl].dflt >= 0 {
+			/* not a sub-command we know; let the default
+			   sub-command have a crack at it */
+			lvl = LEVELS[lvl].dflt
+			cmd = append(cmd, lvl)
+			continue
+
+		} else {
+			args = append(args, rest[0])
+		}
+
+		rest = rest[1:]
+	}
+
+	names := make([]string, len(cmd))
+	for i, lvl := range cmd {
+		names[i] = LEVELS[lvl].command
+	}
+	p.Command = strings.Join(names, " ")
+	p.Args = args
+	p.rest = rest
+	p.path = cmd
+	return true
+}
+
+// find looks for a flag on the top-level, and then on each level
+// of the sub-command path, giving back -1 if it can't be found.
+func (p *PARSER) find(cmd []int, match func(flag int) bool) int {
+	for _, lvl := range append([]int{0}, cmd...) {
+		for _, flag := range LEVELS[lvl].flags {
+			if match(flag) {
+				return flag
+			}
+		}
+	}
+	return -1
+}
+
+func (p *PARSER) parse(cmd []int, args []string) ([]string, bool, error) {
+	for len(args) > 0 {
+		arg := args[0]
+		if len(arg) == 0 || arg == "-" || arg == "--" || arg[0] != '-' {
+			return args, false, nil
+		}
+
+		orig := args
+		args = args[1:]
+		if arg[1] == '-' { /* long option! */
+			name := arg[2:]
+			negated := false
+			flag := p.find(cmd, func(flag int) bool {
+				for _, l := range FLAGS[flag].longs {
+					if l == name {
+						return true
+					}
+				}
+				for _, l := range FLAGS[flag].negations {
+					if l == name {
+						negated = true
+						return true
+					}
+				}
+				return false
+			})
+			if flag < 0 {
+				if i := strings.IndexByte(name, '='); i >= 0 && p.secret(name[:i]) {
+					return orig, true, fmt.Errorf("unrecognized flag ` + "`--%s=<redacted>`" + `", name[:i])
+				}
+				return orig, true, fmt.Errorf("unrecognized flag ` + "`--%s`" + `", name)
+			}
+			if FLAGS[flag].deprecated {
+				p.warn(fmt.Sprintf("flag ` + "`%s`" + `", arg), FLAGS[flag].advice)
+			}
+
+			if negated {
+				p.negate(flag)
+
+			} else if FLAGS[flag].toggle {
+				p.enable(flag, !strings.HasPrefix(name, "no-"))
+
+			} else {
+				if len(args) == 0 {
+					return args, false, fmt.Errorf("missing required value for ` + "`%s`" + ` flag", arg)
+				}
+				if err := p.set(flag, args[0]); err != nil {
+					return args, false, p.hush(flag, arg, err)
+				}
+				args = args[1:]
+			}
+
+		} else { /* short option(s)! */
+			arg = arg[1:]
+			for len(arg) > 0 {
+				name := arg[0:1]
+				arg = arg[1:]
+
+				flag := p.find(cmd, func(flag int) bool {
+					return strings.IndexAny(FLAGS[flag].shorts, name) >= 0
+				})
+				if flag < 0 {
+					/* give back only what's left of the bundle, so that
+					   the flags we've already handled aren't handled
+					   again, if the default sub-command gets a crack */
+					return append([]string{"-" + name + arg}, args...), true, fmt.Errorf("unrecognized flag ` + "`-%s`" + `", name)
+				}
+				if FLAGS[flag].deprecated {
+					p.warn(fmt.Sprintf("flag ` + "`-%s`" + `", name), FLAGS[flag].advice)
+				}
+				if FLAGS[flag].toggle {
+					p.enable(flag, true)
+
+				} else {
+					/* attempt to use the rest of the short block, if there is one,
+					   as the value arg... */
+					if len(arg) > 0 {
+						if err := p.set(flag, arg); err != nil {
+							return args, false, p.hush(flag, "-"+name, err)
+						}
+						break
+					}
+					/* otherwise, we need the next argument in the arg list... */
+					if len(args) == 0 {
+						return args, false, fmt.Errorf("missing required value for ` + "`-%s`" + ` flag", name)
+					}
+					if err := p.set(flag, args[0]); err != nil {
+						return args, false, p.hush(flag, "-"+name, err)
+					}
+					args = args[1:]
+					break
+				}
+			}
+		}
+	}
+
+	return args, false, nil
+}
+`
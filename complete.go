@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+/* Complete works out what could come next on a partially-typed
+   command line, and returns the candidates for the word currently
+   being typed (the last one, or a new, empty word if the line ends
+   in whitespace).  Sub-command names (and aliases) are offered until
+   the first positional argument; flags are offered whenever the word
+   being typed starts with a hyphen.  Hidden options and sub-commands
+   are never offered.
+
+   This is the hook to hand to line-editing libraries, or to call from
+   shell completion scripts. */
+func Complete(thing interface{}, line string) ([]string, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return nil, err
+	}
+	return c.complete(line), nil
+}
+
+func (c context) complete(line string) []string {
+	/* the sentinel lets words() tell us if we're mid-word or not */
+	ws, err := words(line + "\x00")
+	if err != nil || len(ws) == 0 {
+		return nil
+	}
+	args := make([]string, len(ws)-1)
+	for i := range args {
+		args[i] = ws[i].text
+	}
+	prefix := strings.TrimSuffix(ws[len(ws)-1].text, "\x00")
+
+	lvl := c
+	cmd := []string{}
+	positional := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if lvl.Stop {
+			continue
+		}
+		if arg == "--" {
+			/* chained command; start over from the top */
+			lvl, cmd, positional = c, []string{}, false
+			continue
+		}
+
+		if len(arg) > 1 && arg[0] == '-' {
+			if c.wantsValue(cmd, arg) {
+				if i+1 == len(args) {
+					return nil /* we're completing a flag's value */
+				}
+				i++
+			}
+			continue
+		}
+
+		if positional {
+			continue
+		}
+		if sub, ok := lvl.Subs[arg]; ok {
+			lvl = sub
+			cmd = append(cmd, lvl.Command)
+		} else {
+			positional = true
+		}
+	}
+
+	if lvl.Stop {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	l := make([]string, 0)
+	offer := func(s string) {
+		if strings.HasPrefix(s, prefix) && !seen[s] {
+			seen[s] = true
+			l = append(l, s)
+		}
+	}
+
+	if strings.HasPrefix(prefix, "-") {
+		levels := []context{c}
+		at := c
+		for _, name := range cmd {
+			at = at.Subs[name]
+			levels = append(levels, at)
+		}
+		/* flags for the default sub-command(s) are fair game too */
+		for !positional && at.Default != "" {
+			at = at.Subs[at.Default]
+			levels = append(levels, at)
+		}
+
+		for _, lvl := range levels {
+			for _, o := range lvl.Options {
+				if o.Hidden {
+					continue
+				}
+				for _, long := range o.Longs {
+					offer("--" + long)
+				}
+				for _, short := range o.Shorts {
+					offer("-" + string(short))
+				}
+			}
+		}
+
+	} else if !positional {
+		for _, name := range lvl.commands() {
+			if lvl.Subs[name].Hidden {
+				continue
+			}
+			for _, alias := range lvl.aliases(name) {
+				offer(alias)
+			}
+		}
+	}
+
+	sort.Strings(l)
+	return l
+}
+
+/* wantsValue figures out if the given flag (or bundle of short
+   flags) will consume the next argument as its value. */
+func (c context) wantsValue(cmd []string, arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		o, err := c.findLong(cmd, arg[2:])
+		return err == nil && !o.enableable()
+	}
+
+	for i := 1; i < len(arg); i++ {
+		o, err := c.findShort(cmd, arg[i:i+1])
+		if err != nil {
+			return false
+		}
+		if !o.enableable() {
+			/* the rest of the bundle (if any) is the value */
+			return i == len(arg)-1
+		}
+	}
+	return false
+}
@@ -0,0 +1,613 @@
+package conformance_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/jhunt/go-cli"
+	"github.com/jhunt/go-cli/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Generated Parser Conformance Suite")
+}
+
+/* a run is everything there is to observe about a parser
+   working its way through a list of arguments */
+type run struct {
+	Steps    []step
+	Warnings []string
+	Err      string
+}
+
+type step struct {
+	Command string
+	Args    []string
+	State   string /* the options structure, as JSON */
+}
+
+/* parser papers over the differences between a cli.Parser
+   and each of the generated (type-specific) parsers */
+type parser struct {
+	next     func() bool
+	failed   func() error
+	command  *string
+	args     *[]string
+	warnings *[]string
+}
+
+/* engine sets up a parser for a fresh options structure */
+type engine func(thing interface{}, args []string) (parser, error)
+
+func reflective(thing interface{}, args []string) (parser, error) {
+	p, err := cli.NewParser(thing, args)
+	if err != nil {
+		return parser{}, err
+	}
+	return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+}
+
+/* drive runs a parser through all of its (chained) commands, taking
+   a copy of the options structure after each one.  If meddle is set,
+   every field in the structure gets changed after each command, the
+   way an over-eager handler might, to see what gets reset. */
+func drive(e engine, thing interface{}, args []string, meddle bool) run {
+	var r run
+	p, err := e(thing, args)
+	if err != nil {
+		r.Err = err.Error()
+		return r
+	}
+
+	state := func() string {
+		b, err := json.Marshal(thing)
+		if err != nil {
+			panic(err)
+		}
+		return string(b)
+	}
+	r.Steps = append(r.Steps, step{State: state()})
+	for p.next() {
+		r.Steps = append(r.Steps, step{Command: *p.command, Args: *p.args, State: state()})
+		if meddle {
+			tamper(reflect.ValueOf(thing).Elem())
+		}
+	}
+	if err := p.failed(); err != nil {
+		r.Err = err.Error()
+	}
+	r.Warnings = *p.warnings
+	return r
+}
+
+/* tamper changes every value in a structure, recursively.  Pointers
+   are written through, not replaced, unless they are nil. */
+func tamper(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				tamper(v.Field(i))
+			}
+		}
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		tamper(v.Elem())
+
+	case reflect.Slice:
+		v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+
+	case reflect.String:
+		v.SetString(v.String() + "!")
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(v.Float() + 1)
+	}
+}
+
+type scenario struct {
+	fresh     func() interface{}
+	generated engine
+	parse     func(thing interface{}, args []string) (string, []string, error)
+}
+
+var (
+	booleans = scenario{
+		fresh: func() interface{} { return &conformance.Booleans{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewBooleansParser(thing.(*conformance.Booleans), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseBooleansArgs(thing.(*conformance.Booleans), args)
+		},
+	}
+
+	strs = scenario{
+		fresh: func() interface{} { return &conformance.Strings{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewStringsParser(thing.(*conformance.Strings), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseStringsArgs(thing.(*conformance.Strings), args)
+		},
+	}
+
+	numbers = scenario{
+		fresh: func() interface{} { return &conformance.Numbers{Int: 42, Float64: 3.5} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewNumbersParser(thing.(*conformance.Numbers), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseNumbersArgs(thing.(*conformance.Numbers), args)
+		},
+	}
+
+	lists = scenario{
+		fresh: func() interface{} { return &conformance.Lists{Strings: []string{"default"}} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewListsParser(thing.(*conformance.Lists), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseListsArgs(thing.(*conformance.Lists), args)
+		},
+	}
+
+	commands = scenario{
+		fresh: func() interface{} { return &conformance.Commands{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewCommandsParser(thing.(*conformance.Commands), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseCommandsArgs(thing.(*conformance.Commands), args)
+		},
+	}
+
+	chains = scenario{
+		fresh: func() interface{} { return &conformance.Chains{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewChainsParser(thing.(*conformance.Chains), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseChainsArgs(thing.(*conformance.Chains), args)
+		},
+	}
+
+	fullStop = scenario{
+		fresh: func() interface{} { return &conformance.FullStop{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewFullStopParser(thing.(*conformance.FullStop), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseFullStopArgs(thing.(*conformance.FullStop), args)
+		},
+	}
+
+	deprecations = scenario{
+		fresh: func() interface{} { return &conformance.Deprecations{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewDeprecationsParser(thing.(*conformance.Deprecations), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseDeprecationsArgs(thing.(*conformance.Deprecations), args)
+		},
+	}
+
+	defaults = scenario{
+		fresh: func() interface{} { return &conformance.Defaults{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewDefaultsParser(thing.(*conformance.Defaults), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseDefaultsArgs(thing.(*conformance.Defaults), args)
+		},
+	}
+
+	negations = scenario{
+		fresh: func() interface{} { return &conformance.Negations{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewNegationsParser(thing.(*conformance.Negations), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseNegationsArgs(thing.(*conformance.Negations), args)
+		},
+	}
+
+	named = scenario{
+		fresh: func() interface{} { return &conformance.Named{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewNamedParser(thing.(*conformance.Named), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseNamedArgs(thing.(*conformance.Named), args)
+		},
+	}
+
+	secrets = scenario{
+		fresh: func() interface{} { return &conformance.Secrets{} },
+		generated: func(thing interface{}, args []string) (parser, error) {
+			p, err := conformance.NewSecretsParser(thing.(*conformance.Secrets), args)
+			if err != nil {
+				return parser{}, err
+			}
+			return parser{p.Next, p.Error, &p.Command, &p.Args, &p.Warnings}, nil
+		},
+		parse: func(thing interface{}, args []string) (string, []string, error) {
+			return conformance.ParseSecretsArgs(thing.(*conformance.Secrets), args)
+		},
+	}
+)
+
+/* prefilled gives back a variation on a scenario, where the
+   options structure starts out with some non-zero values */
+func prefilled(s scenario, fresh func() interface{}) scenario {
+	s.fresh = fresh
+	return s
+}
+
+var scenarios = map[string]scenario{
+	"Booleans": booleans,
+	"Booleans (with defaults)": prefilled(booleans, func() interface{} {
+		yes := true
+		return &conformance.Booleans{Long: true, Color: true, Maybe: &yes}
+	}),
+	"Strings": strs,
+	"Strings (with defaults)": prefilled(strs, func() interface{} {
+		maybe := "maybe"
+		return &conformance.Strings{Short: "short", Maybe: &maybe}
+	}),
+	"Numbers": numbers,
+	"Lists":   lists,
+	"Lists (with defaults)": prefilled(lists, func() interface{} {
+		l := &conformance.Lists{Strings: []string{"x"}, Tags: []string{"a", "b"}}
+		l.Sub.More = []string{"m"}
+		return l
+	}),
+	"Commands":     commands,
+	"Chains":       chains,
+	"FullStop":     fullStop,
+	"Deprecations": deprecations,
+	"Defaults":     defaults,
+	"Negations":    negations,
+	"Negations (with defaults)": prefilled(negations, func() interface{} {
+		maybe := "maybe"
+		n := &conformance.Negations{Verbose: true, Name: "name", Maybe: &maybe, Level: 3, Tags: []string{"a", "b"}}
+		n.Sub.Only = []uint8{1, 2}
+		return n
+	}),
+	"Named": named,
+	"Named (with defaults)": prefilled(named, func() interface{} {
+		maybe := conformance.Mode("maybe")
+		n := &conformance.Named{Level: 2, Maybe: &maybe, Switch: true, Levels: []conformance.Level{1, 2}}
+		n.Sub.Ports = conformance.Ports{80}
+		return n
+	}),
+	"Secrets": secrets,
+}
+
+/* the command-lines to try, for each scenario; most of these
+   come straight from the go-cli test suite */
+var commandLines = map[string][]string{
+	"Booleans": {
+		``,
+		`-s --long -b`,
+		`-s -s --both --both`,
+		`foo -s bar --long baz`,
+		`-sb`,
+		`-bsC`,
+		`--maybe`,
+		`--no-maybe`,
+		`--no-color`,
+		`--no-maybe --maybe --color --no-color`,
+		`-x`,
+		`--nope`,
+		`-s - -- --long`,
+	},
+	"Booleans (with defaults)": {
+		``,
+		`--no-maybe`,
+		`--no-color -s`,
+	},
+	"Strings": {
+		``,
+		`-s short --long long -b both`,
+		`-s one -s two`,
+		`foo -s bar baz --long quux`,
+		`-sshort`,
+		`-fs short`,
+		`-fsshort`,
+		`-s`,
+		`--long`,
+		`-fs`,
+		`--maybe ''`,
+		`-m maybe -m again`,
+		`-s -- --long`,
+	},
+	"Strings (with defaults)": {
+		``,
+		`-m other`,
+		`--maybe other -s new`,
+	},
+	"Numbers": {
+		``,
+		`--int 1 --int8 -2 --int16 3 --int32 -4 --int64 5`,
+		`--uint 6 --uint8 7 --uint16 8 --uint32 9 --uint64 10`,
+		`--float32 1.25 --float64 -2.5e10`,
+		`-i 0x10`,
+		`-i ten`,
+		`-fi ten`,
+		`-fiten`,
+		`-u -1`,
+		`--int8 128`,
+		`--uint8 256`,
+		`--int64 9223372036854775808`,
+		`--uint64 18446744073709551615`,
+		`--float32 x`,
+		`-F 1e400`,
+		`-i`,
+		`--float64`,
+	},
+	"Lists": {
+		``,
+		`-s one`,
+		`-s one -s two --string three`,
+		`-i 1 -i 2 --int 3 --float 1.5`,
+		`-i 1 -i two`,
+		`-s one sub -s two -m x -m y`,
+		`-s one sub -s two -- sub -m z -- sub -s three`,
+		`sub -- sub -m x`,
+		`-t a,b,c -t d`,
+		`-t 'a\,b,c\\,d\e' sub -t ''`,
+		`-t , -t ,,`,
+		`--ports '80 : 443' --ports 8080`,
+		`--ports '80 : http'`,
+		`-t a sub -t b -- sub -t c,d`,
+	},
+	"Lists (with defaults)": {
+		``,
+		`-s one -t c`,
+		`sub -s one -- sub -s two -- sub`,
+		`-s one sub -s two -- sub -s three`,
+		`sub -m y -- sub -m z -- sub`,
+		`sub -t c -- sub`,
+	},
+	"Commands": {
+		``,
+		`-v hosts -R foo`,
+		`-t prod hosts -H web1 -R`,
+		`hosts -v foo -t prod`,
+		`hosts foo -v -R`,
+		`hosts '' users`,
+		`hosts -- -v foo`,
+		`-h users list -a`,
+		`users ls --all -t prod`,
+		`h -R`,
+		`host --raw`,
+		`-R hosts`,
+		`hosts -a`,
+		`users -a`,
+		`nope -v`,
+	},
+	"Chains": {
+		``,
+		`-k -t prod`,
+		`sub -H host`,
+		`-k sub -H host -- list`,
+		`sub a b -- sub c d -- list e f`,
+		`-t prod sub -t dev -- list -- sub -H x`,
+		`-- sub -H x`,
+		`sub -- -- list`,
+		`list --`,
+		`sub -H`,
+		`sub -- list -x`,
+	},
+	"FullStop": {
+		``,
+		`-D stop all`,
+		`-D stop --hard all`,
+		`stop -- -D`,
+		`--hard stop`,
+		`-D -- stop`,
+	},
+	"Deprecations": {
+		``,
+		`--secret`,
+		`--old value`,
+		`-o value`,
+		`-ho value`,
+		`--new value`,
+		`--ancient`,
+		`legacy -f`,
+		`leg --force -- legacy -- modern`,
+		`--old x modern -o y`,
+	},
+	"Defaults": {
+		``,
+		`-D`,
+		`unknown args`,
+		`-l`,
+		`-D -l foo`,
+		`-x`,
+		`users`,
+		`users -a`,
+		`users delete x`,
+		`st -l`,
+		`-- users -- -D`,
+		`-- x`,
+		`-D --`,
+		`-Dl`,
+		`-Dlx`,
+		`users -- users ls -- status`,
+		`users bob -a`,
+	},
+	"Negations": {
+		``,
+		`--no-verbose`,
+		`-v --no-verbose`,
+		`--no-name`,
+		`-n x --no-name`,
+		`--maybe x`,
+		`--maybe x --no-maybe`,
+		`--no-level -l 4`,
+		`-t x --no-tag`,
+		`-t x --no-tags -t y`,
+		`--no-tag x`,
+		`sub -o 1,2 --no-only`,
+		`sub --no-only -o 3 -- sub -o 4`,
+		`--no-only sub`,
+		`--no-nope`,
+	},
+	"Negations (with defaults)": {
+		``,
+		`--no-verbose --no-name --no-level`,
+		`--no-maybe`,
+		`--no-maybe --maybe x`,
+		`--no-tag`,
+		`--no-tags -t c`,
+		`sub --no-only`,
+		`sub --no-only -- sub`,
+		`-t c -- --no-tag -- sub`,
+	},
+	"Named": {
+		``,
+		`-l 3 -m fast -r 0.5 -s`,
+		`--maybe slow`,
+		`--maybe slow --no-maybe`,
+		`--no-level -l 4`,
+		`-L 1,2 -L 3`,
+		`-L 1 --no-levels`,
+		`-s --no-switch`,
+		`-l x`,
+		`-r nope`,
+		`sub -p 80 -p 443 -M a -M b`,
+		`sub -p 80 -- sub -p 8080`,
+		`sub -p 70000`,
+	},
+	"Secrets": {
+		``,
+		`-t s3cr3t --pin 1234 -k a,b -p 80 -n x`,
+		`--pin hunter2`,
+		`-p hunter2`,
+		`-p80 -phunter2`,
+		`-k a --key b,c`,
+		`--name hunter2`,
+		`--token=hunter2`,
+		`--name=hunter2`,
+		`--password=hunter2 login`,
+		`login -P x -c 7`,
+		`login --code 300`,
+		`login -chunter2`,
+		`login -P x -- login -c 1`,
+	},
+	"Named (with defaults)": {
+		``,
+		`--no-maybe`,
+		`--no-level --no-switch`,
+		`-L 3 --no-levels -L 4`,
+		`sub -p 443`,
+		`sub -p 443 -- sub`,
+	},
+}
+
+var _ = Describe("Generated parsers", func() {
+	for name := range scenarios {
+		name := name
+		s := scenarios[name]
+
+		Describe(name, func() {
+			for _, line := range commandLines[name] {
+				check(s, line)
+			}
+		})
+	}
+})
+
+/* check runs a command-line through both engines, the hard way
+   (NewParser / Next) and the easy way (ParseArgs), and makes sure
+   that everything that can be observed comes out the same. */
+func check(s scenario, line string) {
+	It("handles `"+line+"` exactly like go-cli does", func() {
+		args, err := cli.Split(line)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		expect := drive(reflective, s.fresh(), args, false)
+		got := drive(s.generated, s.fresh(), args, false)
+		Ω(got).Should(Equal(expect))
+
+		expect = drive(reflective, s.fresh(), args, true)
+		got = drive(s.generated, s.fresh(), args, true)
+		Ω(got).Should(Equal(expect))
+
+		a, b := s.fresh(), s.fresh()
+		command, rest, err := cli.ParseArgs(a, args)
+		command2, rest2, err2 := s.parse(b, args)
+		Ω(command2).Should(Equal(command))
+		Ω(rest2).Should(Equal(rest))
+		if err == nil {
+			Ω(err2).ShouldNot(HaveOccurred())
+		} else {
+			Ω(err2).Should(MatchError(err.Error()))
+		}
+		Ω(b).Should(Equal(a))
+	})
+}
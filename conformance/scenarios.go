@@ -0,0 +1,179 @@
+/* Package conformance holds the options structures that are used to
+   check that parsers generated by go-cli-gen behave exactly the same
+   as go-cli itself.  The generated parsers are checked in; regenerate
+   them with `go generate ./conformance` after changing go-cli-gen. */
+package conformance
+
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Booleans
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Strings
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Numbers
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Lists
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Commands
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Chains
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t FullStop
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Deprecations
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Defaults
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Negations
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Named
+//go:generate go run github.com/jhunt/go-cli/cmd/go-cli-gen -t Secrets
+
+type Booleans struct {
+	Short bool  `cli:"-s"`
+	Long  bool  `cli:"--long"`
+	Both  bool  `cli:"-b, --both"`
+	Maybe *bool `cli:"--maybe, --no-maybe"`
+	Color bool  `cli:"-C, --color, --no-color"`
+}
+
+type Strings struct {
+	Short string  `cli:"-s"`
+	Long  string  `cli:"--long"`
+	Both  string  `cli:"-b, --both"`
+	Maybe *string `cli:"-m, --maybe"`
+	Flag  bool    `cli:"-f"`
+}
+
+type Numbers struct {
+	Int     int     `cli:"-i, --int"`
+	Int8    int8    `cli:"--int8"`
+	Int16   int16   `cli:"--int16"`
+	Int32   int32   `cli:"--int32"`
+	Int64   int64   `cli:"--int64"`
+	Uint    uint    `cli:"-u, --uint"`
+	Uint8   uint8   `cli:"--uint8"`
+	Uint16  uint16  `cli:"--uint16"`
+	Uint32  uint32  `cli:"--uint32"`
+	Uint64  uint64  `cli:"--uint64"`
+	Float32 float32 `cli:"--float32"`
+	Float64 float64 `cli:"-F, --float64"`
+	Flag    bool    `cli:"-f"`
+}
+
+type Lists struct {
+	Strings []string  `cli:"-s, --string"`
+	Ints    []int     `cli:"-i, --int"`
+	Floats  []float64 `cli:"--float"`
+	Tags    []string  `cli:"-t, --tags" sep:","`
+	Ports   []uint16  `cli:"--ports" sep:" : "`
+
+	Sub struct {
+		More []string `cli:"-m, --more"`
+	} `cli:"sub"`
+}
+
+type Commands struct {
+	Help    bool   `cli:"-h, -?, --help"`
+	Version bool   `cli:"-v, --version"`
+	Target  string `cli:"-t, --target"`
+
+	Hosts struct {
+		Raw  bool   `cli:"-R, --raw"`
+		Host string `cli:"-H, --host"`
+	} `cli:"hosts, host, h"`
+
+	Users struct {
+		List struct {
+			All bool `cli:"-a, --all"`
+		} `cli:"list, ls"`
+	} `cli:"users"`
+}
+
+type Chains struct {
+	Help     bool   `cli:"-h, -?, --help"`
+	Insecure bool   `cli:"-k, --insecure"`
+	Target   string `cli:"-t, --target"`
+
+	Sub struct {
+		Host string `cli:"-H, --host"`
+	} `cli:"sub"`
+
+	List struct {
+	} `cli:"list"`
+}
+
+type FullStop struct {
+	Help  bool `cli:"-h, -?, --help"`
+	Debug bool `cli:"-D, --debug"`
+
+	Stop struct {
+		Hard bool `cli:"--hard"`
+	} `cli:"stop!"`
+}
+
+type Deprecations struct {
+	Help    bool   `cli:"-h, --help"`
+	Secret  bool   `cli:"--secret" hidden:"true"`
+	Old     string `cli:"-o, --old" deprecated:"use --new instead"`
+	New     string `cli:"--new"`
+	Ancient bool   `cli:"--ancient" deprecated:""`
+
+	Legacy struct {
+		Force bool `cli:"-f, --force"`
+	} `cli:"legacy, leg" deprecated:"use 'modern' instead" hidden:"true"`
+
+	Modern struct {
+	} `cli:"modern"`
+}
+
+type Defaults struct {
+	Debug bool `cli:"-D, --debug"`
+
+	Status struct {
+		Long bool `cli:"-l, --long"`
+	} `cli:"status*, st"`
+
+	Users struct {
+		List struct {
+			All bool `cli:"-a, --all"`
+		} `cli:"list, ls*"`
+
+		Delete struct {
+		} `cli:"delete"`
+	} `cli:"users"`
+}
+
+type Negations struct {
+	Verbose bool     `cli:"-v, --verbose" negatable:"true"`
+	Name    string   `cli:"-n, --name" negatable:"true"`
+	Maybe   *string  `cli:"--maybe" negatable:"true"`
+	Level   int      `cli:"-l, --level" negatable:"true"`
+	Tags    []string `cli:"-t, --tag, --tags" negatable:"true"`
+
+	Sub struct {
+		Only []uint8 `cli:"-o, --only" negatable:"true" sep:","`
+	} `cli:"sub"`
+}
+
+type Level int
+type Mode string
+type Ratio float64
+type Switch bool
+type Port uint16
+type Ports []Port
+
+type Named struct {
+	Level  Level   `cli:"-l, --level" negatable:"true"`
+	Mode   Mode    `cli:"-m, --mode"`
+	Maybe  *Mode   `cli:"--maybe" negatable:"true"`
+	Ratio  Ratio   `cli:"-r, --ratio"`
+	Switch Switch  `cli:"-s, --switch, --no-switch"`
+	Levels []Level `cli:"-L, --levels" sep:"," negatable:"true"`
+
+	Sub struct {
+		Ports Ports  `cli:"-p, --port"`
+		Modes []Mode `cli:"-M, --modes"`
+	} `cli:"sub"`
+}
+
+type Secrets struct {
+	Token string   `cli:"-t, --token" secret:"true"`
+	PIN   int      `cli:"--pin" secret:"true"`
+	Keys  []string `cli:"-k, --key" secret:"true" sep:","`
+	Ports []uint16 `cli:"-p, --port" secret:"true"`
+	Name  string   `cli:"-n, --name"`
+
+	Login struct {
+		Password *string `cli:"-P, --password" secret:"true"`
+		Code     uint8   `cli:"-c, --code" secret:"true"`
+	} `cli:"login"`
+}
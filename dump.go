@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+/* A DumpFormat is a way of rendering the options that
+   Parser.Dump() writes out. */
+type DumpFormat string
+
+const (
+	DumpText DumpFormat = "text" /* a table, for people */
+	DumpJSON DumpFormat = "json"
+	DumpYAML DumpFormat = "yaml"
+)
+
+/* the places that the value of an option can come from */
+const (
+	sourceDefault = "default" /* never set on the command-line */
+	sourceArgv    = "argv"    /* a command-line argument */
+	sourceConfig  = "config"  /* a response file; see ResponseFiles() */
+	sourceEnv     = "env"     /* an environment variable (indirect values) */
+	sourceFile    = "file"    /* a file (indirect values) */
+	sourceStdin   = "stdin"   /* standard input (indirect values) */
+)
+
+/* origin records where the value of an option came from.  Unless it's
+   the default, Arg is the position of the flag on the command-line,
+   counting from 1 (as in os.Args); flags from response files also
+   have the File and Line they were on.  Indirect values also note the
+   environment variable (Env) or file (Path) they were read from. */
+type origin struct {
+	Source string `json:"source" yaml:"source"`
+	Arg    int    `json:"arg,omitempty" yaml:"arg,omitempty"`
+	File   string `json:"file,omitempty" yaml:"file,omitempty"`
+	Line   int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Env    string `json:"env,omitempty" yaml:"env,omitempty"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+/* via notes where an indirect value (as given) was really read from. */
+func (w *origin) via(raw string) {
+	switch {
+	case raw == "-":
+		w.Source = sourceStdin
+	case strings.HasPrefix(raw, "@"):
+		w.Source, w.Path = sourceFile, raw[1:]
+	case strings.HasPrefix(raw, "env:"):
+		w.Source, w.Env = sourceEnv, raw[4:]
+	}
+}
+
+func (w origin) String() string {
+	where := fmt.Sprintf("argument %d", w.Arg)
+	if w.File != "" {
+		where = fmt.Sprintf("%s:%d, from argument %d", w.File, w.Line, w.Arg)
+	}
+
+	switch w.Source {
+	case sourceArgv:
+		return where
+	case sourceConfig:
+		return fmt.Sprintf("%s:%d (from argument %d)", w.File, w.Line, w.Arg)
+	case sourceEnv:
+		return fmt.Sprintf("$%s (per %s)", w.Env, where)
+	case sourceFile:
+		return fmt.Sprintf("%s (per %s)", w.Path, where)
+	case sourceStdin:
+		return fmt.Sprintf("standard input (per %s)", where)
+	}
+	return sourceDefault
+}
+
+/* dumped is what Dump() writes out for each option. */
+type dumped struct {
+	Command string      `json:"command" yaml:"command"`
+	Flag    string      `json:"flag" yaml:"flag"`
+	Field   string      `json:"field" yaml:"field"`
+	Value   interface{} `json:"value" yaml:"value"`
+	Origin  origin      `json:"origin" yaml:"origin"`
+}
+
+/* Dump writes out the options that the current command sees (the one
+   the last call to Next() set up, or just the global options, before
+   that), in the given format, along with where each value came from:
+   the default, a command-line argument (by position), a response file
+   (by file and line), or, for indirect values, the environment, a file
+   or standard input.  Options are listed from the top-level on down,
+   in the order they were defined.  Because everything is reverted
+   between chained commands, each command sees (and Dump() reports)
+   the global options as they were given before the first command,
+   plus whatever that command itself changed.
+
+   Values are given the way they would be on the command-line: as
+   strings, lists of strings (for lists), or null (for unset pointers).
+   The values of `secret` options are always redacted. */
+func (p *Parser) Dump(w io.Writer, format DumpFormat) error {
+	l := make([]dumped, 0)
+	c := p.c
+	for i := 0; ; i++ {
+		for _, o := range c.Options {
+			l = append(l, o.dump(strings.Join(p.path[:i], " ")))
+		}
+		if i == len(p.path) {
+			break
+		}
+		c = c.Subs[p.path[i]]
+	}
+
+	switch format {
+	case DumpText:
+		t := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(t, "COMMAND\tFLAG\tVALUE\tORIGIN\n")
+		for _, d := range l {
+			cmd := d.Command
+			if cmd == "" {
+				cmd = "(top-level)"
+			}
+			fmt.Fprintf(t, "%s\t%s\t%s\t%s\n", cmd, d.Flag, tabulated(d.Value), d.Origin)
+		}
+		return t.Flush()
+
+	case DumpJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Command string   `json:"command"`
+			Options []dumped `json:"options"`
+		}{strings.Join(p.path, " "), l})
+
+	case DumpYAML:
+		b, err := yaml.Marshal(struct {
+			Command string   `yaml:"command"`
+			Options []dumped `yaml:"options"`
+		}{strings.Join(p.path, " "), l})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return fmt.Errorf("unrecognized dump format `%s`", format)
+}
+
+/* dump describes an option (defined on the given sub-command), and
+   its current value, for Dump(). */
+func (o *option) dump(command string) dumped {
+	d := dumped{
+		Command: command,
+		Flag:    o.flag(false),
+		Field:   o.Field,
+		Origin:  o.Origin,
+	}
+	if d.Flag == "" {
+		d.Flag = o.flag(true)
+	}
+	if d.Origin.Source == "" {
+		d.Origin.Source = sourceDefault
+	}
+
+	v := *o.Value
+	switch {
+	case o.Flag != nil:
+		d.Value = o.Flag.String()
+	case o.Secret:
+		d.Value = redacted
+	case o.Kind == reflect.Ptr && v.IsNil():
+		d.Value = nil
+	case o.Kind == reflect.Slice:
+		l := make([]string, v.Len())
+		for i := range l {
+			l[i] = o.format(v.Index(i))
+		}
+		d.Value = l
+	default:
+		d.Value = o.format(v)
+	}
+	return d
+}
+
+/* tabulated renders a dumped value for the text table, quoting
+   strings (and list items) the way Quote() would. */
+func tabulated(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "(unset)"
+	case []string:
+		if len(v) == 0 {
+			return "(empty)"
+		}
+		return Quote(v)
+	case string:
+		if v == redacted {
+			return v
+		}
+		return quote(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
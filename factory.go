@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"reflect"
+)
+
+/* A Factory hands out fresh options structures, all of the same type,
+   each one a deep copy of the prototype it was made from (defaults and
+   all).  Nothing is shared between the structures it hands out, nor
+   between the parsers that fill them in, so a single Factory can be
+   used by as many goroutines as you like, all at once. */
+type Factory struct {
+	proto    reflect.Value
+	settings []Setting
+}
+
+/* NewFactory makes a Factory from a pointer to a prototype options
+   structure, which is checked over right away.  The prototype is
+   copied, so changing it afterwards has no effect on the Factory.
+
+   Any settings given are used for every parse, ahead of the settings
+   passed to NewParser() and ParseArgs(). */
+func NewFactory(prototype interface{}, settings ...Setting) (*Factory, error) {
+	if _, err := inspect(prototype); err != nil {
+		return nil, err
+	}
+
+	return &Factory{
+		proto:    clone(reflect.ValueOf(prototype).Elem()),
+		settings: settings,
+	}, nil
+}
+
+/* New gives back a pointer to a new copy of the prototype. */
+func (f *Factory) New() interface{} {
+	v := reflect.New(f.proto.Type())
+	v.Elem().Set(clone(f.proto))
+	return v.Interface()
+}
+
+/* NewParser is like the package-level NewParser(), except that
+   it parses into a new copy of the prototype, which it gives back. */
+func (f *Factory) NewParser(args []string, settings ...Setting) (interface{}, *Parser, error) {
+	thing := f.New()
+	p, err := NewParser(thing, args, f.with(settings)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return thing, p, nil
+}
+
+/* ParseArgs is like the package-level ParseArgs(), except that
+   it parses into a new copy of the prototype, which it gives back. */
+func (f *Factory) ParseArgs(args []string, settings ...Setting) (interface{}, string, []string, error) {
+	thing := f.New()
+	command, args, err := ParseArgs(thing, args, f.with(settings)...)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return thing, command, args, nil
+}
+
+/* with tacks some per-call settings onto the end of the Factory's
+   own, without touching the latter (which other goroutines share). */
+func (f *Factory) with(settings []Setting) []Setting {
+	l := make([]Setting, 0, len(f.settings)+len(settings))
+	return append(append(l, f.settings...), settings...)
+}
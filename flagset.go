@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/* mount is a flag.FlagSet, along with the (canonical or aliased)
+   sub-command path that its flags get attached to. */
+type mount struct {
+	path []string
+	fs   *flag.FlagSet
+}
+
+/* MountFlags attaches all of the flags defined on a flag.FlagSet to
+   the given sub-command (a space-separated path, like "deploy app", or
+   "" for the top-level), as if they had been defined in the options
+   structure.  This is handy for packages that insist on registering
+   their settings on a FlagSet (i.e. flag.CommandLine), like glog does.
+
+   Single-letter flags become short options (`-v`), the rest become
+   long options (`--log_dir`).  Values are handed to the FlagSet, via
+   its Set() method, so the FlagSet knows what has been set.  Between
+   chained commands, mounted flags get set back to whatever String()
+   gave back before the command was parsed.
+
+   Flags that clash with an option already defined at (or above) that
+   sub-command are rejected by NewParser(). */
+func MountFlags(path string, fs *flag.FlagSet) Setting {
+	return func(s *settings) {
+		s.mounts = append(s.mounts, mount{path: strings.Fields(path), fs: fs})
+	}
+}
+
+/* mounted is a flag.Value that goes through the FlagSet, so that
+   fs.Visit() and friends see what we set. */
+type mounted struct {
+	fs   *flag.FlagSet
+	name string
+}
+
+func (m mounted) String() string {
+	return m.fs.Lookup(m.name).Value.String()
+}
+
+func (m mounted) Set(s string) error {
+	if err := m.fs.Set(m.name, s); err != nil {
+		flag := "--" + m.name
+		if len(m.name) == 1 {
+			flag = "-" + m.name
+		}
+		return fmt.Errorf("invalid value `%s` for `%s` flag: %s", s, flag, err)
+	}
+	return nil
+}
+
+func (m mounted) IsBoolFlag() bool {
+	b, ok := m.fs.Lookup(m.name).Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+/* mounted attaches the flags from the given mounts to a (bound) context,
+   and validates the result. */
+func (c context) mounted(mounts []mount) (context, error) {
+	for _, m := range mounts {
+		opts := make([]*option, 0)
+		m.fs.VisitAll(func(f *flag.Flag) {
+			opts = append(opts, flagged(mounted{fs: m.fs, name: f.Name}, f))
+		})
+
+		var err error
+		if c, err = c.mount(m.path, opts); err != nil {
+			return c, err
+		}
+	}
+
+	if len(mounts) > 0 {
+		if err := validate(c); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+/* mount adds options to the sub-command at the end of the path,
+   making sure that all of that sub-command's aliases get them too. */
+func (c context) mount(path []string, opts []*option) (context, error) {
+	if len(path) == 0 {
+		c.Options = append(c.Options[:len(c.Options):len(c.Options)], opts...)
+		return c, nil
+	}
+
+	sub, ok := c.Subs[path[0]]
+	if !ok {
+		return c, fmt.Errorf("unable to mount flags on unrecognized sub-command `%s`", path[0])
+	}
+	sub, err := sub.mount(path[1:], opts)
+	if err != nil {
+		return c, err
+	}
+
+	subs := make(map[string]context, len(c.Subs))
+	for name, other := range c.Subs {
+		if other.Command == sub.Command {
+			subs[name] = sub
+		} else {
+			subs[name] = other
+		}
+	}
+	c.Subs = subs
+	return c, nil
+}
+
+/* flagged makes an option out of a flag from a flag.FlagSet. */
+func flagged(v flag.Value, f *flag.Flag) *option {
+	o := &option{
+		Flag:   v,
+		Type:   reflect.TypeOf(""),
+		Kind:   reflect.String,
+		Longs:  make([]string, 0),
+		Shorts: "",
+	}
+	if b, ok := v.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		o.Type = reflect.TypeOf(false)
+		o.Kind = reflect.Bool
+	}
+
+	/* a stand-in, so that everything that looks at
+	   the value of an option has something to look at */
+	value := reflect.New(o.Type).Elem()
+	o.Value = &value
+
+	if len(f.Name) == 1 {
+		o.Shorts = f.Name
+	} else {
+		o.Longs = append(o.Longs, f.Name)
+	}
+	o.Field = f.Name
+	o.Help = f.Usage
+	if f.DefValue != "" && !(o.Kind == reflect.Bool && f.DefValue == "false") {
+		dflt := f.DefValue
+		o.Default = &dflt
+	}
+	return o
+}
+
+/* FlagSet makes a flag.FlagSet out of the options defined for a single
+   sub-command (a space-separated path, or "" for the top-level), for
+   handing to libraries that want one.  Setting flags on the FlagSet
+   sets the options in the structure, same as parsing would.
+
+   Each short and long name of an option becomes a separate flag (they
+   all set the same thing).  As on the command line, setting the `no-`
+   form of a boolean option to true turns it off, and setting the `no-`
+   form of any other `negatable` option to true clears it out. */
+func FlagSet(thing interface{}, command string) (*flag.FlagSet, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "(top-level)"
+	for _, cmd := range strings.Fields(command) {
+		sub, ok := c.Subs[cmd]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized sub-command `%s`", cmd)
+		}
+		c = sub
+		name = command
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	for _, o := range c.Options {
+		for _, s := range o.Shorts {
+			fs.Var(&optionValue{o: o}, string(s), o.Help)
+		}
+		for _, l := range o.Longs {
+			off := o.enableable() && strings.HasPrefix(l, "no-")
+			fs.Var(&optionValue{o: o, off: off, neg: o.negates(l)}, l, o.Help)
+		}
+	}
+	return fs, nil
+}
+
+/* optionValue is a flag.Value that sets an option; off-values are
+   for the `no-` forms of boolean options, and neg-values are for
+   the `no-` forms of other (negatable) options. */
+type optionValue struct {
+	o   *option
+	off bool
+	neg bool
+}
+
+func (v *optionValue) String() string {
+	if v.o == nil {
+		/* the flag package does this to figure out zero values */
+		return ""
+	}
+	if v.neg {
+		return "false"
+	}
+	if v.o.Secret {
+		/* so that the flag package doesn't show it as a default */
+		return ""
+	}
+	s := v.o.format(*v.o.Value)
+	if v.off {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return strconv.FormatBool(!b)
+		}
+	}
+	return s
+}
+
+func (v *optionValue) Set(s string) error {
+	if v.neg {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid value `%s` (should be true or false)", s)
+		}
+		if b {
+			v.o.negate()
+		}
+		return nil
+	}
+	if v.o.enableable() {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid value `%s` (should be true or false)", s)
+		}
+		v.o.enable(b != v.off)
+		return nil
+	}
+	return v.o.hush(v.o.flag(false), v.o.set(s))
+}
+
+func (v *optionValue) IsBoolFlag() bool {
+	return v.o != nil && (v.neg || v.o.enableable())
+}
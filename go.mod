@@ -3,7 +3,6 @@ module github.com/jhunt/go-cli
 go 1.14
 
 require (
-	github.com/jhunt/go-snapshot v0.0.0-20170309042712-92984e0ad8d8
 	github.com/onsi/ginkgo v1.2.1-0.20170306185959-c27b3c46852f
 	github.com/onsi/gomega v0.0.0-20170306183512-1de7ab2df910
 	golang.org/x/crypto v0.0.0-20170302193244-40541ccb1c6e
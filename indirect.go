@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+/* defaultIndirectLimit is how much an indirect value can be,
+   unless IndirectLimit() says otherwise. */
+const defaultIndirectLimit = 64 << 10
+
+/* IndirectLimit caps the size of the values that `indirect` options
+   will take from files, standard input, or the environment.  Anything
+   bigger is an error.  The default limit is 64KiB. */
+func IndirectLimit(limit ByteSize) Setting {
+	return func(s *settings) {
+		s.limit = &limit
+	}
+}
+
+/* set sets an option from a value given on the command-line (for
+   the given flag), dereferencing it first if the option is indirect.
+   Errors about the values of secret options don't include them. */
+func (p *Parser) set(opt *option, flag, raw string) error {
+	if opt.Indirect {
+		v, err := p.deref(flag, raw)
+		if err != nil {
+			return err
+		}
+		opt.Origin.via(raw)
+		raw = v
+	}
+	return opt.hush(flag, opt.set(raw))
+}
+
+/* deref works out the actual value of an indirect option:
+
+     @path      the contents of the file at path
+     -          the contents of standard input
+     env:NAME   the value of the NAME environment variable
+
+   Trailing newlines are trimmed from files and standard input (but
+   not from environment variables), the same way the shell trims them
+   from $(...).  Standard input can only be read once per Parser.
+
+   A backslash in front of any of these (or in front of another
+   backslash) is dropped, and the rest of the value is taken as-is. */
+func (p *Parser) deref(flag, raw string) (string, error) {
+	limit := ByteSize(defaultIndirectLimit)
+	if p.s.limit != nil {
+		limit = *p.s.limit
+	}
+
+	switch {
+	case raw == "-":
+		if p.stdin != "" {
+			return "", fmt.Errorf("unable to read value for `%s` flag from standard input: it was already read for `%s`", flag, p.stdin)
+		}
+		p.stdin = flag
+		return slurp(flag, "standard input", os.Stdin, limit)
+
+	case strings.HasPrefix(raw, "@"):
+		f, err := os.Open(raw[1:])
+		if err != nil {
+			return "", fmt.Errorf("unable to read value for `%s` flag from `%s`: %s", flag, raw, err)
+		}
+		defer f.Close()
+		return slurp(flag, "`"+raw+"`", f, limit)
+
+	case strings.HasPrefix(raw, "env:"):
+		v, ok := os.LookupEnv(raw[4:])
+		if !ok {
+			return "", fmt.Errorf("unable to read value for `%s` flag from `%s`: no such environment variable", flag, raw)
+		}
+		if ByteSize(len(v)) > limit {
+			return "", fmt.Errorf("value for `%s` flag from `%s` is too big (the limit is %s)", flag, raw, limit)
+		}
+		return v, nil
+	}
+	return unescapeIndirect(raw), nil
+}
+
+/* slurp reads an indirect value in, up to the limit. */
+func slurp(flag, from string, r io.Reader, limit ByteSize) (string, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return "", fmt.Errorf("unable to read value for `%s` flag from %s: %s", flag, from, err)
+	}
+	if ByteSize(len(b)) > limit {
+		return "", fmt.Errorf("value for `%s` flag from %s is too big (the limit is %s)", flag, from, limit)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+/* indirected figures out if a value would be dereferenced
+   (or unescaped) by deref(), were it given on the command-line. */
+func indirected(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "@") || strings.HasPrefix(s, "env:") || strings.HasPrefix(s, "\\")
+}
+
+/* escapeIndirect protects a literal value from deref(). */
+func escapeIndirect(s string) string {
+	if indirected(s) {
+		return "\\" + s
+	}
+	return s
+}
+
+/* unescapeIndirect is the opposite of escapeIndirect(). */
+func unescapeIndirect(s string) string {
+	if strings.HasPrefix(s, "\\") && indirected(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
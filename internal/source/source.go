@@ -0,0 +1,256 @@
+/* Package source recreates go-cli options structures from the Go
+   source code that defines them, for the tools (like go-cli-docs and
+   go-cli-gen) that run at `go generate` time, when the program that
+   defines the structure can't be run. */
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jhunt/go-cli"
+)
+
+/* Locate finds the source directory for a package, which can
+   be given either as a directory or as an import path. */
+func Locate(pkg string) (string, error) {
+	if st, err := os.Stat(pkg); err == nil && st.IsDir() {
+		return filepath.Abs(pkg)
+	}
+
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkg).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to find package `%s`: %s", pkg, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+/* Rebuild parses the Go source in dir, finds the named structure type,
+   and recreates it (or at least, the parts of it go-cli cares about)
+   via reflection, giving back a pointer to a fresh value of it, along
+   with the name of the package it was found in.
+
+   Reflection can't recreate named types (like `type Level int`), so
+   options declared with them get the underlying type instead.  The
+   names are given back separately, keyed by the path of field names
+   that leads to the option (i.e. "Build.Level"); for lists and
+   pointers, it's the name of the element type. */
+func Rebuild(dir, name string) (interface{}, string, map[string]string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	r := resolver{
+		types: make(map[string]ast.Expr),
+		busy:  make(map[string]bool),
+		cli:   make(map[string]bool),
+		named: make(map[string]string),
+	}
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	found := ""
+	for _, pkg := range names {
+		for _, f := range pkgs[pkg].Files {
+			for _, imp := range f.Imports {
+				if path, _ := strconv.Unquote(imp.Path.Value); path == "github.com/jhunt/go-cli" {
+					if imp.Name != nil {
+						r.cli[imp.Name.Name] = true
+					} else {
+						r.cli["cli"] = true
+					}
+				}
+			}
+			for _, decl := range f.Decls {
+				if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+					for _, spec := range gen.Specs {
+						ts := spec.(*ast.TypeSpec)
+						r.types[ts.Name.Name] = ts.Type
+						if ts.Name.Name == name {
+							found = pkg
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if found == "" {
+		return nil, "", nil, fmt.Errorf("no type named `%s` found in %s", name, dir)
+	}
+	t, err := r.resolve(ast.NewIdent(name), "")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, "", nil, fmt.Errorf("type `%s` is not a structure", name)
+	}
+	return reflect.New(t).Interface(), found, r.named, nil
+}
+
+type resolver struct {
+	types map[string]ast.Expr /* type declarations in the package */
+	busy  map[string]bool     /* named types being resolved (for cycles) */
+	cli   map[string]bool     /* names that go-cli is imported as */
+	named map[string]string   /* named types of options, by field path */
+}
+
+var basics = map[string]reflect.Type{
+	"bool":    reflect.TypeOf(false),
+	"string":  reflect.TypeOf(""),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"rune":    reflect.TypeOf(rune(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"byte":    reflect.TypeOf(byte(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+}
+
+/* exported are the types that go-cli itself provides for options. */
+var exported = map[string]reflect.Type{
+	"ByteSize": reflect.TypeOf(cli.ByteSize(0)),
+	"Percent":  reflect.TypeOf(cli.Percent(0)),
+}
+
+/* resolve works out the type that a type expression stands for.
+   Structures note the named types of their fields, under path. */
+func (r resolver) resolve(e ast.Expr, path string) (reflect.Type, error) {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if t, ok := basics[e.Name]; ok {
+			return t, nil
+		}
+		def, ok := r.types[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("unable to resolve type `%s`", e.Name)
+		}
+		if r.busy[e.Name] {
+			return nil, fmt.Errorf("type `%s` refers to itself", e.Name)
+		}
+		r.busy[e.Name] = true
+		defer delete(r.busy, e.Name)
+		return r.resolve(def, path)
+
+	case *ast.ParenExpr:
+		return r.resolve(e.X, path)
+
+	case *ast.StarExpr:
+		t, err := r.resolve(e.X, path)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PtrTo(t), nil
+
+	case *ast.ArrayType:
+		if e.Len != nil {
+			return nil, fmt.Errorf("go-cli does not support arrays; use a slice instead")
+		}
+		t, err := r.resolve(e.Elt, path)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(t), nil
+
+	case *ast.StructType:
+		fields := make([]reflect.StructField, 0)
+		for _, f := range e.Fields.List {
+			if f.Tag == nil {
+				continue
+			}
+			tag, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := reflect.StructTag(tag).Lookup("cli"); !ok {
+				continue
+			}
+
+			for _, name := range f.Names {
+				if !ast.IsExported(name.Name) {
+					continue
+				}
+				field := strings.TrimPrefix(path+"."+name.Name, ".")
+				t, err := r.resolve(f.Type, field)
+				if err != nil {
+					return nil, err
+				}
+				if t.Kind() != reflect.Struct {
+					if n := r.nameOf(f.Type); n != "" {
+						r.named[field] = n
+					}
+				}
+				fields = append(fields, reflect.StructField{
+					Name: name.Name,
+					Type: t,
+					Tag:  reflect.StructTag(tag),
+				})
+			}
+		}
+		return reflect.StructOf(fields), nil
+
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok && r.cli[pkg.Name] {
+			if t, ok := exported[e.Sel.Name]; ok {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("unable to resolve type `%s.%s` from another package", e.X, e.Sel.Name)
+	}
+	return nil, fmt.Errorf("unsupported type expression %T", e)
+}
+
+/* nameOf works out the name of the (element) type that a type
+   expression refers to, as it would be written in the package, or
+   gives back "" if it's one of the basic types.  Named lists and
+   pointers (i.e. `type Levels []Level`) are seen through. */
+func (r resolver) nameOf(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if _, ok := basics[e.Name]; ok {
+			return ""
+		}
+		switch def := r.types[e.Name].(type) {
+		case *ast.ParenExpr, *ast.StarExpr, *ast.ArrayType:
+			return r.nameOf(def)
+		}
+		return e.Name
+
+	case *ast.ParenExpr:
+		return r.nameOf(e.X)
+
+	case *ast.StarExpr:
+		return r.nameOf(e.X)
+
+	case *ast.ArrayType:
+		return r.nameOf(e.Elt)
+
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			return pkg.Name + "." + e.Sel.Name
+		}
+	}
+	return ""
+}
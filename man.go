@@ -0,0 +1,361 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/* ManOptions supplies the details about a program that can't be
+   gleaned from its options structure, for ManPage() and ManPages(). */
+type ManOptions struct {
+	Name    string /* name of the program, i.e. "vault" */
+	Section string /* manual section; defaults to "1" */
+	Date    string /* date of the last change, i.e. "2017-03-09" */
+	Version string /* version of the program */
+	Summary string /* one-line description, for the NAME section */
+}
+
+/* ManPage renders a manual page, in roff, for the program described
+   by the options structure.  Every (non-hidden) sub-command gets its
+   own sub-section, with its aliases, its options and any special
+   behavior (full-stops, defaults, deprecation) spelled out. */
+func ManPage(thing interface{}, o ManOptions) (string, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return "", err
+	}
+
+	m := newManual(o)
+	m.header(m.o.Name, m.o.Summary)
+	m.synopsis(c, nil)
+	if c.Default != "" {
+		m.describe(c, m.o.Name, nil)
+	}
+	m.options("OPTIONS", c.Options)
+
+	if len(c.visible()) > 0 {
+		m.section("COMMANDS")
+		m.commands(c, nil)
+	}
+	return m.String(), nil
+}
+
+/* ManPages is like ManPage, except that it renders a separate page for
+   each (non-hidden) sub-command, named for the program and the full
+   sub-command path, git-style: `tool-gen(1)`, `tool-users-delete(1)`.
+   Pages are returned keyed by file name, i.e. "tool-gen.1". */
+func ManPages(thing interface{}, o ManOptions) (map[string]string, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]string)
+	o = newManual(o).o
+	c.manPages(pages, o, nil, nil, nil)
+	return pages, nil
+}
+
+func (c context) manPages(pages map[string]string, o ManOptions, cmd, aliases []string, inherited []*option) {
+	name := strings.Join(append([]string{o.Name}, cmd...), "-")
+	m := newManual(o)
+
+	if len(cmd) == 0 {
+		m.header(name, o.Summary)
+	} else {
+		m.header(name, summary(c.Help))
+	}
+	m.synopsis(c, cmd)
+	if len(cmd) > 0 {
+		m.describe(c, cmd[len(cmd)-1], aliases)
+	} else if c.Default != "" {
+		m.describe(c, o.Name, nil)
+	}
+	m.options("OPTIONS", c.Options)
+	m.options("GLOBAL OPTIONS", inherited)
+
+	subs := c.visible()
+	if len(subs) > 0 {
+		m.section("COMMANDS")
+		for _, sub := range subs {
+			page := strings.Join(append(append([]string{o.Name}, cmd...), sub), "-")
+			m.item(fmt.Sprintf(`\fB%s\fR`, roff(sub)))
+			m.text(summary(c.Subs[sub].Help))
+			m.text(fmt.Sprintf(`See \fB%s\fR(%s).`, roff(page), o.Section))
+		}
+	}
+
+	if len(cmd) > 0 {
+		m.section("SEE ALSO")
+		m.text(fmt.Sprintf(`\fB%s\fR(%s)`, roff(strings.Join(append([]string{o.Name}, cmd[:len(cmd)-1]...), "-")), o.Section))
+	}
+	pages[name+"."+o.Section] = m.String()
+
+	for _, sub := range subs {
+		c.Subs[sub].manPages(pages, o, append(append([]string{}, cmd...), sub), c.aliases(sub)[1:],
+			append(append([]*option{}, inherited...), c.Options...))
+	}
+}
+
+/* visible returns the canonical names of the sub-commands
+   at this level that haven't been hidden. */
+func (c context) visible() []string {
+	l := make([]string, 0)
+	for _, name := range c.commands() {
+		if !c.Subs[name].Hidden {
+			l = append(l, name)
+		}
+	}
+	return l
+}
+
+type manual struct {
+	strings.Builder
+	o ManOptions
+}
+
+func newManual(o ManOptions) *manual {
+	if o.Section == "" {
+		o.Section = "1"
+	}
+	return &manual{o: o}
+}
+
+func (m *manual) line(format string, args ...interface{}) {
+	fmt.Fprintf(m, format+"\n", args...)
+}
+
+func (m *manual) section(name string) {
+	m.line(`.SH "%s"`, name)
+}
+
+func (m *manual) item(tag string) {
+	m.line(".TP")
+	m.line("%s", tag)
+}
+
+func (m *manual) text(s string) {
+	if s != "" {
+		m.line("%s", s)
+	}
+}
+
+func (m *manual) header(name, summary string) {
+	version := strings.TrimSpace(m.o.Name + " " + m.o.Version)
+	m.line(`.TH "%s" "%s" "%s" "%s" "%s Manual"`, roff(strings.ToUpper(name)), m.o.Section,
+		roff(m.o.Date), roff(version), roff(m.o.Name))
+	m.section("NAME")
+	if summary == "" {
+		m.line("%s", roff(name))
+	} else {
+		m.line(`%s \- %s`, roff(name), roff(summary))
+	}
+}
+
+func (m *manual) synopsis(c context, cmd []string) {
+	m.section("SYNOPSIS")
+	m.line(".B %s", roff(m.o.Name))
+	m.line(`[\fIOPTIONS\fR]`)
+	for _, name := range cmd {
+		m.line(`\fB%s\fR`, roff(name))
+	}
+	if len(cmd) > 0 && len(c.Options) > 0 {
+		m.line(`[\fIOPTIONS\fR]`)
+	}
+	if len(c.visible()) > 0 {
+		m.line(`\fICOMMAND\fR`)
+	}
+	m.line(`[\fIARGUMENTS\fR...]`)
+}
+
+/* describe explains a sub-command: its help, aliases,
+   and any special behavior it may have. */
+func (m *manual) describe(c context, name string, aliases []string) {
+	m.section("DESCRIPTION")
+	for _, s := range c.explain(name, aliases) {
+		m.line(".PP")
+		m.line("%s", roff(s))
+	}
+}
+
+func (m *manual) commands(c context, parent []string) {
+	for _, name := range c.visible() {
+		sub := c.Subs[name]
+		cmd := append(append([]string{}, parent...), name)
+
+		m.line(`.SS "%s"`, roff(strings.Join(cmd, " ")))
+		for i, s := range sub.explain(name, c.aliases(name)[1:]) {
+			if i > 0 {
+				m.line(".PP")
+			}
+			m.line("%s", roff(s))
+		}
+		for _, o := range sub.Options {
+			m.option(o)
+		}
+		m.commands(sub, cmd)
+	}
+}
+
+func (m *manual) options(title string, l []*option) {
+	shown := false
+	for _, o := range l {
+		if o.Hidden {
+			continue
+		}
+		if !shown {
+			m.section(title)
+			shown = true
+		}
+		m.option(o)
+	}
+}
+
+func (m *manual) option(o *option) {
+	if o.Hidden {
+		return
+	}
+
+	flags := make([]string, 0)
+	for _, short := range o.Shorts {
+		flags = append(flags, fmt.Sprintf(`\fB\-%s\fR`, roff(string(short))))
+	}
+	for _, long := range o.Longs {
+		if o.negates(long) {
+			continue
+		}
+		flags = append(flags, fmt.Sprintf(`\fB\-\-%s\fR`, strings.Replace(roff(long), "-", `\-`, -1)))
+	}
+	tag := strings.Join(flags, ", ")
+	if mv := o.metavar(); mv != "" {
+		tag += fmt.Sprintf(` \fI%s\fR`, mv)
+	}
+
+	m.item(tag)
+	for i, s := range o.explain(true) {
+		if i > 0 {
+			m.line(".br")
+		}
+		m.line("%s", roff(s))
+	}
+}
+
+/* explain lists the sentences that describe a sub-command,
+   starting with its own help text, if it has any. */
+func (c context) explain(name string, aliases []string) []string {
+	l := make([]string, 0)
+	if c.Help != "" {
+		l = append(l, c.Help)
+	}
+	if c.Deprecated != nil {
+		l = append(l, deprecation(fmt.Sprintf("The `%s` sub-command", name), *c.Deprecated)+".")
+	}
+	if len(aliases) > 0 {
+		l = append(l, fmt.Sprintf("Also available as: %s.", strings.Join(aliases, ", ")))
+	}
+	if c.Stop {
+		l = append(l, fmt.Sprintf("No options are recognized after `%s`; all remaining arguments are passed along as-is.", name))
+	}
+	if c.Default != "" {
+		l = append(l, fmt.Sprintf("If no sub-command is given, `%s` is assumed.", c.Default))
+	}
+	return l
+}
+
+/* explain lists the sentences that describe an option, starting
+   with its own help text, if it has any.  The default is only
+   mentioned if dflt is set; Markdown has a column for it. */
+func (o *option) explain(dflt bool) []string {
+	l := make([]string, 0)
+	if o.Help != "" {
+		l = append(l, o.Help)
+	}
+	if o.Kind == reflect.Slice {
+		l = append(l, "May be given more than once.")
+		if o.Sep != "" {
+			l = append(l, fmt.Sprintf("Each value may also be a list, separated by \"%s\".", o.Sep))
+		}
+	}
+	if len(o.Negations) > 0 {
+		l = append(l, fmt.Sprintf("Can be cleared out with `--%s`.", o.Negations[0]))
+	}
+	if s := o.radix(); s != "" {
+		l = append(l, s)
+	}
+	if o.Indirect {
+		l = append(l, indirectly)
+	}
+	if dflt && o.Default != nil {
+		l = append(l, fmt.Sprintf("Defaults to %s.", *o.Default))
+	}
+	if o.Deprecated != nil {
+		l = append(l, deprecation("This option", *o.Deprecated)+".")
+	}
+	return l
+}
+
+/* indirectly explains where the values of indirect options can come from. */
+const indirectly = "May be read from a file (@path), standard input (-) or the environment (env:NAME)."
+
+/* radix explains which base an integer option takes its
+   values in, or gives back "" if it's just plain old base 10. */
+func (o *option) radix() string {
+	t := o.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if !integral(t) || o.Base == 10 {
+		return ""
+	}
+	if o.Base == 0 {
+		return "May be given in hexadecimal (0x...), octal (0o...) or binary (0b...)."
+	}
+	return fmt.Sprintf("Given in base %d.", o.Base)
+}
+
+/* metavar names the kind of value an option takes,
+   or gives back "" if the option doesn't take one. */
+func (o *option) metavar() string {
+	if o.enableable() {
+		return ""
+	}
+	switch o.Unit {
+	case bytesUnit:
+		return "SIZE"
+	case percentUnit:
+		return "PERCENT"
+	}
+
+	t := o.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "N"
+
+	case reflect.Float32, reflect.Float64:
+		return "NUMBER"
+	}
+	return "VALUE"
+}
+
+/* summary gives back the first line of a (potentially
+   multi-line) bit of help text. */
+func summary(help string) string {
+	return strings.TrimSpace(strings.SplitN(help, "\n", 2)[0])
+}
+
+/* roff escapes text so that troff doesn't try to interpret it. */
+func roff(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		if strings.HasPrefix(lines[i], ".") || strings.HasPrefix(lines[i], "'") {
+			lines[i] = `\&` + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
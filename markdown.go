@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* MarkdownOptions supplies the details about a program that can't be
+   gleaned from its options structure, for Markdown() and MarkdownFiles(). */
+type MarkdownOptions struct {
+	Name    string /* name of the program, i.e. "vault" */
+	Version string /* version of the program (optional) */
+	Summary string /* one-line description (optional) */
+}
+
+/* Markdown renders reference documentation for the program described
+   by the options structure, as a single Markdown document.  There is a
+   table of contents up top, followed by a section for the program's
+   global options, and a section for each (non-hidden) sub-command
+   path, each with an anchor that the table of contents links to.
+
+   Output is entirely deterministic (sub-commands are sorted, options
+   keep their structure order), which makes it suitable for golden-file
+   testing, and for checking in. */
+func Markdown(thing interface{}, o MarkdownOptions) (string, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title(o))
+	if o.Summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", o.Summary)
+	}
+
+	paths := c.paths(nil)
+	if len(paths) > 0 {
+		fmt.Fprintf(&b, "## Contents\n\n")
+		fmt.Fprintf(&b, "- [%s](#%s)\n", o.Name, anchor(title(o)))
+		for _, cmd := range paths {
+			full := strings.Join(append([]string{o.Name}, cmd...), " ")
+			fmt.Fprintf(&b, "%s- [%s](#%s)\n", strings.Repeat("  ", len(cmd)), full, anchor(full))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	link := func(cmd []string) string {
+		if len(cmd) == 0 {
+			return "#" + anchor(title(o))
+		}
+		return "#" + anchor(strings.Join(append([]string{o.Name}, cmd...), " "))
+	}
+	c.markdown(&b, o, "#", nil, nil, link)
+	for _, cmd := range paths {
+		lvl, aliases := c.walk(cmd)
+		lvl.markdown(&b, o, "##", cmd, aliases, link)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+/* MarkdownFiles is like Markdown(), except that it renders a separate
+   document for the program itself, and for each (non-hidden) sub-command
+   path, linked to one another.  Documents are returned keyed by file
+   name, i.e. "vault.md" and "vault-gen.md". */
+func MarkdownFiles(thing interface{}, o MarkdownOptions) (map[string]string, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return nil, err
+	}
+
+	file := func(cmd []string) string {
+		return strings.Join(append([]string{o.Name}, cmd...), "-") + ".md"
+	}
+
+	files := make(map[string]string)
+	for _, cmd := range append([][]string{nil}, c.paths(nil)...) {
+		lvl, aliases := c.walk(cmd)
+
+		var b strings.Builder
+		if len(cmd) == 0 {
+			fmt.Fprintf(&b, "# %s\n\n", title(o))
+			if o.Summary != "" {
+				fmt.Fprintf(&b, "%s\n\n", o.Summary)
+			}
+		}
+		lvl.markdown(&b, o, "#", cmd, aliases, file)
+		if len(cmd) > 0 {
+			fmt.Fprintf(&b, "See also: [%s](%s)\n\n",
+				strings.Join(append([]string{o.Name}, cmd[:len(cmd)-1]...), " "), file(cmd[:len(cmd)-1]))
+		}
+		files[file(cmd)] = strings.TrimSuffix(b.String(), "\n")
+	}
+	return files, nil
+}
+
+/* paths lists the full paths to all of the (non-hidden)
+   sub-commands, depth-first, in sorted order. */
+func (c context) paths(parent []string) [][]string {
+	l := make([][]string, 0)
+	for _, name := range c.visible() {
+		cmd := append(append([]string{}, parent...), name)
+		l = append(l, cmd)
+		l = append(l, c.Subs[name].paths(cmd)...)
+	}
+	return l
+}
+
+/* walk follows a sub-command path down from this level, and gives back
+   the context at the end of it, along with the other names it has. */
+func (c context) walk(cmd []string) (context, []string) {
+	aliases := []string{}
+	for _, name := range cmd {
+		aliases = c.aliases(name)[1:]
+		c = c.Subs[name]
+	}
+	return c, aliases
+}
+
+func (c context) markdown(b *strings.Builder, o MarkdownOptions, h string, cmd, aliases []string, link func([]string) string) {
+	full := strings.Join(append([]string{o.Name}, cmd...), " ")
+	if len(cmd) > 0 {
+		fmt.Fprintf(b, "%s %s\n\n", h, full)
+		for _, s := range c.explain(cmd[len(cmd)-1], aliases) {
+			fmt.Fprintf(b, "%s\n\n", s)
+		}
+	} else if c.Default != "" {
+		for _, s := range c.explain(o.Name, nil) {
+			fmt.Fprintf(b, "%s\n\n", s)
+		}
+	}
+
+	usage := []string{o.Name, "[OPTIONS]"}
+	if len(cmd) > 0 {
+		usage = append(usage, cmd...)
+		if len(c.Options) > 0 {
+			usage = append(usage, "[OPTIONS]")
+		}
+	}
+	if len(c.visible()) > 0 {
+		usage = append(usage, "COMMAND")
+	}
+	usage = append(usage, "[ARGUMENTS...]")
+	fmt.Fprintf(b, "```\n%s\n```\n\n", strings.Join(usage, " "))
+
+	if table := markdownOptions(c.Options); table != "" {
+		fmt.Fprintf(b, "%s# Options\n\n%s\n", h, table)
+	}
+	if len(cmd) > 0 {
+		fmt.Fprintf(b, "Options from [%s](%s) may also be given.\n\n",
+			strings.Join(append([]string{o.Name}, cmd[:len(cmd)-1]...), " "), link(cmd[:len(cmd)-1]))
+	}
+
+	if subs := c.visible(); len(subs) > 0 {
+		fmt.Fprintf(b, "%s# Commands\n\n", h)
+		for _, name := range subs {
+			sub := append(append([]string{}, cmd...), name)
+			fmt.Fprintf(b, "- [%s](%s)", strings.Join(append([]string{o.Name}, sub...), " "), link(sub))
+			if s := summary(c.Subs[name].Help); s != "" {
+				fmt.Fprintf(b, " - %s", s)
+			}
+			fmt.Fprintf(b, "\n")
+		}
+		fmt.Fprintf(b, "\n")
+	}
+}
+
+/* markdownOptions renders a table of the (non-hidden) options,
+   or gives back "" if there aren't any to render. */
+func markdownOptions(l []*option) string {
+	var b strings.Builder
+	for _, o := range l {
+		if o.Hidden {
+			continue
+		}
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "| Flags | Value | Default | Description |\n")
+			fmt.Fprintf(&b, "| ----- | ----- | ------- | ----------- |\n")
+		}
+
+		flags := make([]string, 0)
+		for _, short := range o.Shorts {
+			flags = append(flags, fmt.Sprintf("`-%c`", short))
+		}
+		for _, long := range o.Longs {
+			if o.negates(long) {
+				continue
+			}
+			flags = append(flags, fmt.Sprintf("`--%s`", long))
+		}
+
+		value := ""
+		if mv := o.metavar(); mv != "" {
+			value = "`" + mv + "`"
+		}
+		dflt := ""
+		if o.Default != nil {
+			dflt = "`" + *o.Default + "`"
+		}
+
+		about := o.explain(false)
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", strings.Join(flags, ", "),
+			value, cell(dflt), cell(strings.Join(about, " ")))
+	}
+	return b.String()
+}
+
+func title(o MarkdownOptions) string {
+	if o.Version != "" {
+		return o.Name + " " + o.Version
+	}
+	return o.Name
+}
+
+/* anchor works out the anchor that most Markdown renderers
+   (GitHub's, in particular) generate for a heading. */
+func anchor(heading string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(heading) {
+		switch {
+		case c == ' ':
+			b.WriteRune('-')
+		case c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'):
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+/* cell makes text safe to put in a table cell. */
+func cell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
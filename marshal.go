@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/* MarshalOptions tunes how MarshalWith() renders an options structure. */
+type MarshalOptions struct {
+	/* A pointer to a structure (of the same type as the one being
+	   marshaled) holding the values that the receiving end starts
+	   out with.  Values that match are left out of the argument list.
+	   If not set, the zero values are assumed. */
+	Defaults interface{}
+
+	/* Include every value that can be expressed on the command-line,
+	   even if it matches the default. */
+	All bool
+
+	/* Include the actual values of `secret` options.  Otherwise, they
+	   are replaced with "<redacted>", and the argument list won't parse
+	   back into the same structure.  Secrets that match the default
+	   are left out, same as any other value. */
+	Secrets bool
+}
+
+/* Marshal is the reverse of ParseArgs(): it renders the values in an
+   options structure back into a list of arguments, for the given
+   sub-command path (i.e. "users delete", or "" for none), that will
+   parse back into an identical structure.
+
+   The argument list is canonical: global options come first, then
+   each sub-command (by its canonical name), followed by its own
+   options, in the order they were defined.  Long flag names are used
+   wherever they exist, booleans that are off are given in their
+   `--no-` form (if they have one), and lists are given as repeated
+   flags (or as a single delimited value, if they have a `sep` tag).
+   Only values that differ from the zero values are included; see
+   MarshalWith() for other defaults.  Options belonging to sub-commands
+   that are not on the path are ignored.
+
+   Some values just can't be expressed; there's no way to unset a
+   pointer, empty out a list (unless it has a `sep` tag), or turn off
+   a boolean without a `--no-` form, unless the option is `negatable`.
+   Those get you an error, unless they match the default.
+
+   The values of `secret` options are redacted; see MarshalWith()
+   and MarshalOptions.Secrets if you really do need them. */
+func Marshal(thing interface{}, command string) ([]string, error) {
+	return MarshalWith(thing, command, MarshalOptions{})
+}
+
+/* MarshalWith is like Marshal(), but with more control over which
+   values are considered defaults, and whether or not to leave them out. */
+func MarshalWith(thing interface{}, command string, o MarshalOptions) ([]string, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return nil, err
+	}
+
+	dflts := o.Defaults
+	if dflts == nil {
+		t := reflect.TypeOf(thing)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		dflts = reflect.New(t).Interface()
+	} else if reflect.TypeOf(dflts) != reflect.TypeOf(thing) {
+		return nil, fmt.Errorf("defaults (a %T) must be of the same type as the options structure (a %T)", dflts, thing)
+	}
+	d, err := inspect(dflts)
+	if err != nil {
+		return nil, err
+	}
+
+	argv := []string{}
+	cmd := strings.Fields(command)
+	for i := 0; ; i++ {
+		l := []string{}
+		for j, opt := range c.Options {
+			more, err := opt.marshal(d.Options[j], o.All && !c.Stop, o.Secrets)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, more...)
+		}
+		if c.Stop && len(l) > 0 {
+			return nil, fmt.Errorf("unable to marshal options for `%s` sub-command: no options are recognized after it", c.Command)
+		}
+		argv = append(argv, l...)
+
+		if i == len(cmd) {
+			break
+		}
+		sub, ok := c.Subs[cmd[i]]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized sub-command `%s`", cmd[i])
+		}
+		argv = append(argv, sub.Command)
+		c, d = sub, d.Subs[cmd[i]]
+	}
+	return argv, nil
+}
+
+/* marshal renders the arguments needed to set this option to its
+   current value, given that it starts out with the default value.
+   Unless secrets is set, the values of secret options are redacted. */
+func (o *option) marshal(dflt *option, all, secrets bool) ([]string, error) {
+	v := *o.Value
+	same := equivalent(v, *dflt.Value)
+	if same && !all {
+		return nil, nil
+	}
+
+	/* fail when we can't say what we need to */
+	cannot := func(problem string) ([]string, error) {
+		if same {
+			return nil, nil
+		}
+		flag := o.flag(false)
+		if flag == "" {
+			flag = o.flag(true)
+		}
+		return nil, fmt.Errorf("unable to marshal `%s` flag: %s", flag, problem)
+	}
+
+	/* negatable options can be cleared out, in one shot */
+	if len(o.Negations) > 0 {
+		if (o.Kind == reflect.Ptr && v.IsNil()) || (o.Kind == reflect.Slice && v.Len() == 0) {
+			return []string{"--" + o.Negations[0]}, nil
+		}
+	}
+
+	if o.Kind == reflect.Ptr {
+		if v.IsNil() {
+			return cannot("there is no way to unset it")
+		}
+		v = v.Elem()
+	}
+
+	if o.enableable() {
+		flag := o.flag(!v.Bool())
+		if flag == "" {
+			if v.Bool() {
+				return cannot("there is no way to turn it on")
+			}
+			return cannot("there is no `--no-` form to turn it off")
+		}
+		return []string{flag}, nil
+	}
+
+	/* indirect values mustn't be mistaken for @files and such,
+	   and secret values mustn't be seen at all (unless asked for) */
+	literal := func(s string) string {
+		if o.Indirect {
+			s = escapeIndirect(s)
+		}
+		return o.reveal(s, secrets)
+	}
+
+	flag := o.flag(false)
+	if o.Kind != reflect.Slice {
+		return []string{flag, literal(o.format(v))}, nil
+	}
+
+	if o.Sep != "" {
+		/* delimited lists go in one shot (empty and all) */
+		l := make([]string, v.Len())
+		for i := range l {
+			l[i] = o.format(v.Index(i))
+		}
+		if len(l) == 1 && l[0] == "" {
+			return cannot("a lone empty value looks just like an empty list")
+		}
+		return []string{flag, literal(join(l, o.Sep))}, nil
+	}
+
+	if v.Len() == 0 {
+		return cannot("there is no way to empty it out")
+	}
+	l := make([]string, 0, 2*v.Len())
+	for i := 0; i < v.Len(); i++ {
+		l = append(l, flag, literal(o.format(v.Index(i))))
+	}
+	return l, nil
+}
+
+/* flag picks the canonical flag for an option: the first long
+   name, or failing that, the first short one.  For booleans, the
+   `--no-` forms are kept separate; off picks between them. */
+func (o *option) flag(off bool) string {
+	for _, long := range o.Longs {
+		if !o.enableable() || strings.HasPrefix(long, "no-") == off {
+			return "--" + long
+		}
+	}
+	if !off && len(o.Shorts) > 0 {
+		return "-" + o.Shorts[0:1]
+	}
+	return ""
+}
+
+/* equivalent compares two option values, treating
+   nil and empty lists as one and the same. */
+func equivalent(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -7,23 +7,35 @@ import (
 /* Parse looks through os.Args, and returns the sub-command name
    (or "" for none), the remaining positional arguments, and any
    error that was encountered. */
-func Parse(thing interface{}) (string, []string, error) {
-	return ParseArgs(thing, os.Args[1:])
+func Parse(thing interface{}, settings ...Setting) (string, []string, error) {
+	return ParseArgs(thing, os.Args[1:], settings...)
+}
+
+/* ParseString is like ParseArgs(), except that it splits the given
+   command string into arguments first, the same way Split() does. */
+func ParseString(thing interface{}, command string, settings ...Setting) (string, []string, error) {
+	args, err := Split(command)
+	if err != nil {
+		return "", nil, err
+	}
+	return ParseArgs(thing, args, settings...)
 }
 
 /* ParseArgs is like Parse(), except that it operates on an explicit
    list of arguments, instead of implicitly using os.Args. */
-func ParseArgs(thing interface{}, args []string) (string, []string, error) {
-	p, err := NewParser(thing, args)
+func ParseArgs(thing interface{}, args []string, settings ...Setting) (string, []string, error) {
+	p, err := NewParser(thing, args, settings...)
 	if err != nil {
 		return "", nil, err
 	}
 
-	if len(p.rest) == 0 {
+	if len(p.rest) == 0 && p.c.Default == "" {
 		return p.Command, p.Args, p.Error()
 	}
 
-	if p.rest[0] == "--" {
+	/* a leading separator ends the options, unless there's a
+	   default sub-command to fall into, the way Next() would */
+	if len(p.rest) > 0 && p.rest[0] == "--" && p.c.Default == "" {
 		return p.Command, append(p.Args, p.rest[1:]...), p.Error()
 	}
 
@@ -2,49 +2,79 @@ package cli
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
-
-	"github.com/jhunt/go-snapshot"
 )
 
 type Parser struct {
-	Command string
-	Args    []string
-
-	c    context
-	err  error
-	rest []string
-	snap snapshot.Snapshot
+	Command  string
+	Args     []string
+	Warnings []string
+
+	c     context
+	s     settings
+	err   error
+	rest  []string
+	from  []origin /* where each argument came from, for Dump() */
+	path  []string /* sub-command path of the last Next() */
+	ran   bool
+	stdin string /* the flag that read standard input, if any */
 }
 
-func NewParser(thing interface{}, args []string) (*Parser, error) {
-	c, err := reflectOnIt(thing)
+func NewParser(thing interface{}, args []string, settings ...Setting) (*Parser, error) {
+	/* reflect (or look up what we already know) and make
+	   sure we didn't do anything semantically invalid... */
+	c, err := inspect(thing)
 	if err != nil {
 		return nil, err
 	}
 
-	/* make sure we didn't do anything semantically invalid... */
-	if err := validate(c); err != nil {
-		return nil, err
-	}
-
 	/* keep track of the salient details */
 	p := Parser{
-		c:       c,
-		Command: "",
-		Args:    []string{},
+		c:        c,
+		s:        configure(settings),
+		Command:  "",
+		Args:     []string{},
+		Warnings: []string{},
 	}
 
-	/* parse the globals, but stop at the first non-option */
-	if p.rest, err = parse(&c, nil, args); err != nil {
+	/* attach any flags from the standard library's flag package */
+	if p.c, err = c.mounted(p.s.mounts); err != nil {
 		return nil, err
 	}
+	c = p.c
 
-	/* snapshot so that we can revert to the globally-specified globals */
-	if p.snap, err = snapshot.Take(thing); err != nil {
-		return nil, err
+	/* single-dash long options mustn't look like short option bundles */
+	if p.s.single {
+		if err = validateSingleDash(c, nil, nil); err != nil {
+			return nil, err
+		}
 	}
 
+	/* swap out any @file arguments for what's in those files,
+	   keeping track of where each argument came from */
+	p.from = make([]origin, len(args))
+	for i := range args {
+		p.from[i] = origin{Source: sourceArgv, Arg: i + 1}
+	}
+	if p.s.responses {
+		if args, p.from, err = expandResponses(args); err != nil {
+			return nil, err
+		}
+	}
+
+	/* parse the globals, but stop at the first non-option */
+	if p.rest, err = p.parse(nil, args); err != nil {
+		/* leave flags we don't know to the default sub-command */
+		if _, ok := err.(unrecognizedFlag); !ok || c.Default == "" {
+			return nil, err
+		}
+	}
+
+	/* remember the option values, so that we can revert to
+	   the globally-specified globals before each command */
+	p.c.save()
+
 	return &p, nil
 }
 
@@ -52,23 +82,38 @@ func (p *Parser) Error() error {
 	return p.err
 }
 
+/* warn records a warning, and passes it along to the configured
+   warning sink, if there is one. */
+func (p *Parser) warn(msg string) {
+	p.Warnings = append(p.Warnings, msg)
+	if p.s.warn != nil {
+		p.s.warn(msg)
+	}
+}
+
+/* more tells if there is another command for Next() to parse. */
+func (p *Parser) more() bool {
+	/* a default sub-command gets one shot at running
+	   even if we were only given global options */
+	return len(p.rest) > 0 || (!p.ran && p.c.Default != "")
+}
+
 func (p *Parser) Next() bool {
-	if len(p.rest) == 0 {
+	if !p.more() {
 		return false
 	}
+	p.ran = true
 
 	/* skip the chain separator */
-	if p.rest[0] == "--" {
+	if len(p.rest) > 0 && p.rest[0] == "--" {
 		p.rest = p.rest[1:]
 	}
 
-	/* revert the global state */
-	err := p.snap.Revert()
-	if err != nil {
-		p.err = err
-		return false
-	}
+	/* revert the global state, and whatever
+	   the last command may have changed */
+	p.c.restore(p.path)
 
+	var err error
 	rest := p.rest     // contains the rest of the unparsed options
 	args := []string{} // positional arguments
 	cmd := []string{}  // sub-command stack
@@ -80,18 +125,44 @@ func (p *Parser) Next() bool {
 			break
 		}
 
-		if rest, err = parse(&p.c, cmd, rest); err != nil {
+		if p.s.strict && len(args) > 0 {
+			/* options are over; everything up to the
+			   chain separator is a positional argument */
+			for len(rest) > 0 && rest[0] != "--" {
+				args = append(args, rest[0])
+				rest = rest[1:]
+			}
+			if len(rest) > 0 {
+				rest = rest[1:]
+			}
+			break
+		}
+
+		if rest, err = p.parse(cmd, rest); err != nil {
+			/* flags we don't know might belong to the default
+			   sub-command, if we haven't committed to this one */
+			if _, ok := err.(unrecognizedFlag); ok && len(args) == 0 && lvl.Default != "" {
+				lvl = lvl.Subs[lvl.Default]
+				cmd = append(cmd, lvl.Command)
+				continue
+			}
+			p.path = cmd
 			p.err = err
 			return false
 		}
 
-		if len(rest) == 0 {
-			/* out of options */
-			break
-		}
+		if len(rest) == 0 || rest[0] == "--" {
+			/* out of options; fall into the default sub-command,
+			   if we haven't seen any positional arguments yet */
+			if len(args) == 0 && lvl.Default != "" {
+				lvl = lvl.Subs[lvl.Default]
+				cmd = append(cmd, lvl.Command)
+				continue
+			}
 
-		if rest[0] == "--" {
-			rest = rest[1:]
+			if len(rest) > 0 {
+				rest = rest[1:]
+			}
 			break
 		}
 
@@ -101,6 +172,16 @@ func (p *Parser) Next() bool {
 		} else if sub, ok := lvl.Subs[rest[0]]; ok {
 			lvl = sub
 			cmd = append(cmd, lvl.Command)
+			if lvl.Deprecated != nil {
+				p.warn(deprecation(fmt.Sprintf("sub-command `%s`", rest[0]), *lvl.Deprecated))
+			}
+
+		} else if lvl.Default != "" {
+			/* not a sub-command we know; let the default
+			   sub-command have a crack at it */
+			lvl = lvl.Subs[lvl.Default]
+			cmd = append(cmd, lvl.Command)
+			continue
 
 		} else {
 			args = append(args, rest[0])
@@ -112,23 +193,55 @@ func (p *Parser) Next() bool {
 	p.Command = strings.Join(cmd, " ")
 	p.Args = args
 	p.rest = rest
+	p.path = cmd
 	return true
 }
 
-func parse(c *context, cmd, args []string) ([]string, error) {
+func (p *Parser) parse(cmd, args []string) ([]string, error) {
 	for len(args) > 0 {
 		arg := args[0]
 		if len(arg) == 0 || arg == "-" || arg == "--" || arg[0] != '-' {
 			return args, nil
 		}
+		if p.s.negatives && negative(arg) {
+			if _, err := p.c.findShort(cmd, arg[1:2]); err != nil {
+				return args, nil
+			}
+		}
 
+		orig, at := args, len(p.from)-len(args)
 		args = args[1:]
+		var (
+			opt  *option
+			dash string
+		)
 		if arg[1] == '-' { /* long option! */
 			name := arg[2:]
-			opt, err := c.findLong(cmd, name)
+			if i := strings.IndexByte(name, '='); i >= 0 && p.s.single {
+				name = name[:i]
+			}
+			o, err := p.c.findLong(cmd, name)
 			if err != nil {
-				return args, err
+				return orig, p.c.hushFlag(name, err)
 			}
+			opt, dash = o, "--"
+
+		} else if o := p.singleDash(cmd, arg[1:]); o != nil { /* long option, with one dash */
+			opt, dash = o, "-"
+		}
+
+		if opt != nil {
+			/* with SingleDashLongs(), long options can be given
+			   their value in the same argument, as `name=value` */
+			name := arg[len(dash):]
+			value, given := "", false
+			if i := strings.IndexByte(name, '='); i >= 0 && p.s.single {
+				name, value, given = name[:i], name[i+1:], true
+			}
+			if opt.Deprecated != nil {
+				p.warn(deprecation(fmt.Sprintf("flag `%s%s`", dash, name), *opt.Deprecated))
+			}
+			opt.Origin = p.from[at]
 
 			/* now we need to determine if we have a value arg or not.
 			   `cli` uses a simple heuristic that works well in practice:
@@ -136,17 +249,33 @@ func parse(c *context, cmd, args []string) ([]string, error) {
 			     - bool receivers do not take value args
 			     - everything else takes a value arg
 			*/
-			if opt.enableable() {
-				opt.enable(!strings.HasPrefix(name, "no-"))
+			if opt.negates(name) {
+				if given {
+					return args, fmt.Errorf("the `%s%s` flag does not take a value", dash, name)
+				}
+				opt.negate()
+
+			} else if opt.enableable() {
+				on := true
+				if given {
+					b, err := strconv.ParseBool(value)
+					if err != nil {
+						return args, fmt.Errorf("invalid value `%s` for `%s%s` flag (should be true or false)", value, dash, name)
+					}
+					on = b
+				}
+				opt.enable(on != strings.HasPrefix(name, "no-"))
 
 			} else {
-				if len(args) == 0 {
-					return args, fmt.Errorf("missing required value for `%s` flag", arg)
+				if !given {
+					if len(args) == 0 {
+						return args, fmt.Errorf("missing required value for `%s%s` flag", dash, name)
+					}
+					value, args = args[0], args[1:]
 				}
-				if err = opt.set(args[0]); err != nil {
+				if err := p.set(opt, dash+name, value); err != nil {
 					return args, err
 				}
-				args = args[1:]
 			}
 
 		} else { /* short option(s)! */
@@ -155,10 +284,17 @@ func parse(c *context, cmd, args []string) ([]string, error) {
 				name := arg[0:1]
 				arg = arg[1:]
 
-				opt, err := c.findShort(cmd, name)
+				opt, err := p.c.findShort(cmd, name)
 				if err != nil {
-					return args, err
+					/* give back only what's left of the bundle, so that
+					   the flags we've already handled aren't handled
+					   again, if the default sub-command gets a crack */
+					return append([]string{"-" + name + arg}, args...), err
 				}
+				if opt.Deprecated != nil {
+					p.warn(deprecation(fmt.Sprintf("flag `-%s`", name), *opt.Deprecated))
+				}
+				opt.Origin = p.from[at]
 				if opt.enableable() {
 					opt.enable(true)
 
@@ -166,7 +302,7 @@ func parse(c *context, cmd, args []string) ([]string, error) {
 					/* attempt to use the rest of the short block, if there is one,
 					   as the value arg... */
 					if len(arg) > 0 {
-						if err = opt.set(arg); err != nil {
+						if err = p.set(opt, "-"+name, arg); err != nil {
 							return args, err
 						}
 						break
@@ -175,7 +311,7 @@ func parse(c *context, cmd, args []string) ([]string, error) {
 					if len(args) == 0 {
 						return args, fmt.Errorf("missing required value for `-%s` flag", name)
 					}
-					if err = opt.set(args[0]); err != nil {
+					if err = p.set(opt, "-"+name, args[0]); err != nil {
 						return args, err
 					}
 					args = args[1:]
@@ -187,3 +323,39 @@ func parse(c *context, cmd, args []string) ([]string, error) {
 
 	return args, nil
 }
+
+/* singleDash looks up a single-dash argument (sans dash) as a long
+   option, if SingleDashLongs() is in effect, ignoring any `=value`. */
+func (p *Parser) singleDash(cmd []string, name string) *option {
+	if !p.s.single {
+		return nil
+	}
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		name = name[:i]
+	}
+	opt, err := p.c.findLong(cmd, name)
+	if err != nil {
+		return nil
+	}
+	return opt
+}
+
+/* negative figures out if an argument looks like a negative number,
+   starting with a digit (or a decimal point) right after the dash,
+   so that bundles of letters like `-inf` don't count. */
+func negative(arg string) bool {
+	if len(arg) < 2 || !(arg[1] == '.' || (arg[1] >= '0' && arg[1] <= '9')) {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
+
+/* deprecation formats the warning issued when something deprecated
+   is used, with the (optional) advice from the `deprecated` tag. */
+func deprecation(what, advice string) string {
+	if advice == "" {
+		return fmt.Sprintf("%s is deprecated", what)
+	}
+	return fmt.Sprintf("%s is deprecated; %s", what, advice)
+}
@@ -4,9 +4,113 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
+var (
+	splitter  = regexp.MustCompile(" *, *")
+	shortFlag = regexp.MustCompile("^-([a-zA-Z0-9?])$")
+	longFlag  = regexp.MustCompile("^--([a-zA-Z0-9?][a-zA-Z0-9?-]+)$")
+)
+
+/* specs caches the validated contexts for each type of options
+   structure that we've seen, so that we only have to reflect on each
+   type once.  Each use binds the cached context to the value at hand. */
+var specs sync.Map /* reflect.Type => context */
+
+/* inspect reflects on the options structure, and validates it,
+   using (and populating) the cache wherever it can. */
+func inspect(thing interface{}) (context, error) {
+	t := reflect.TypeOf(thing)
+	v := reflect.ValueOf(thing)
+	if t != nil && t.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().CanSet() {
+		if c, ok := specs.Load(t); ok {
+			return c.(context).bind(v.Elem()), nil
+		}
+	}
+
+	c, err := reflectOnIt(thing)
+	if err != nil {
+		return c, err
+	}
+	if err := validate(c); err != nil {
+		return c, err
+	}
+	if t.Kind() == reflect.Ptr && cacheable(t.Elem()) {
+		/* cache a copy, so that parsing doesn't muck with it (bound to
+		   a value of our own, so that we don't hang on to the caller's) */
+		specs.Store(t, c.bind(reflect.New(t.Elem()).Elem()))
+	}
+	return c, nil
+}
+
+/* cacheable figures out if reflecting on a type of options structure
+   always turns out the same, regardless of the values in it.  Non-nil
+   pointers get followed, so pointers to anything but booleans and
+   strings (which work either way) get in the way. */
+func cacheable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if _, set := field.Tag.Lookup("cli"); !set {
+			continue
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Ptr:
+			if k := field.Type.Elem().Kind(); k != reflect.Bool && k != reflect.String {
+				return false
+			}
+		case reflect.Struct:
+			if !cacheable(field.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/* bind makes a copy of a (cached) context that operates on the given
+   structure value, which must be of the same type as the one that the
+   context was reflected from. */
+func (c context) bind(root reflect.Value) context {
+	options := make([]*option, len(c.Options))
+	for i, o := range c.Options {
+		v := root.FieldByIndex(o.Index)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+
+		bound := *o
+		bound.Type = v.Type()
+		bound.Kind = v.Kind()
+		bound.Value = &v
+		bound.Default = bound.defaultOf(v)
+		options[i] = &bound
+	}
+	c.Options = options
+
+	/* aliases share their options, so bind each sub-command only once */
+	subs := make(map[string]context, len(c.Subs))
+	for name, sub := range c.Subs {
+		if name == sub.Command {
+			subs[name] = sub.bind(root)
+		}
+	}
+	for name, sub := range c.Subs {
+		subs[name] = subs[sub.Command]
+	}
+	c.Subs = subs
+	return c
+}
+
 func reflectOnIt(thing interface{}) (context, error) {
 	t := reflect.TypeOf(thing)
 	v := reflect.ValueOf(thing)
@@ -20,10 +124,10 @@ func reflectOnIt(thing interface{}) (context, error) {
 		Options: make([]*option, 0),
 		Subs:    make(map[string]context),
 	}
-	return reflectSomeMore(c, t, &v)
+	return reflectSomeMore(c, t, &v, nil)
 }
 
-func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, error) {
+func reflectSomeMore(c context, t reflect.Type, v *reflect.Value, at []int) (context, error) {
 
 	if t.Kind() != reflect.Struct {
 		return c, fmt.Errorf("go-cli only operates on structures")
@@ -42,6 +146,7 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 		}
 
 		tag := field.Tag.Get("cli")
+		index := append(append([]int{}, at...), i)
 
 		t := field.Type
 		v := v.Field(i)
@@ -50,6 +155,47 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 			v = v.Elem()
 		}
 
+		sep, delimited := field.Tag.Lookup("sep")
+		if delimited && t.Kind() != reflect.Slice {
+			return c, fmt.Errorf("invalid sep tag '%s' on field %s (only lists can be delimited)", sep, field.Name)
+		}
+		if delimited && (sep == "" || strings.Contains(sep, "\\")) {
+			return c, fmt.Errorf("invalid sep tag '%s' on field %s", sep, field.Name)
+		}
+
+		unit, err := unitOf(field, t)
+		if err != nil {
+			return c, err
+		}
+		base, err := baseOf(field, t, unit)
+		if err != nil {
+			return c, err
+		}
+
+		negatable := false
+		if tag, set := field.Tag.Lookup("negatable"); set {
+			if negatable, err = strconv.ParseBool(tag); err != nil {
+				return c, fmt.Errorf("invalid negatable tag '%s' on field %s", tag, field.Name)
+			}
+			if negatable && t.Kind() == reflect.Struct {
+				return c, fmt.Errorf("invalid negatable tag '%s' on field %s (only options can be negated)", tag, field.Name)
+			}
+		}
+
+		indirect := false
+		if tag, set := field.Tag.Lookup("indirect"); set {
+			if indirect, err = strconv.ParseBool(tag); err != nil {
+				return c, fmt.Errorf("invalid indirect tag '%s' on field %s", tag, field.Name)
+			}
+		}
+
+		secret := false
+		if tag, set := field.Tag.Lookup("secret"); set {
+			if secret, err = strconv.ParseBool(tag); err != nil {
+				return c, fmt.Errorf("invalid secret tag '%s' on field %s", tag, field.Name)
+			}
+		}
+
 		switch t.Kind() {
 		case reflect.Slice:
 			if !v.IsValid() {
@@ -66,6 +212,14 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 			if err != nil {
 				return c, err
 			}
+			if o.about, err = annotations(field); err != nil {
+				return c, err
+			}
+			o.Index = index
+			o.Sep = sep
+			o.Unit = unit
+			o.Base = base
+			o.Default = o.defaultOf(v)
 			c.Options = append(c.Options, o)
 			break
 
@@ -75,6 +229,11 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 				if err != nil {
 					return c, err
 				}
+				if o.about, err = annotations(field); err != nil {
+					return c, err
+				}
+				o.Default = o.defaultOf(v)
+				o.Index = index
 				c.Options = append(c.Options, o)
 
 			} else if t.Elem().Kind() == reflect.String {
@@ -82,6 +241,11 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 				if err != nil {
 					return c, err
 				}
+				if o.about, err = annotations(field); err != nil {
+					return c, err
+				}
+				o.Default = o.defaultOf(v)
+				o.Index = index
 				c.Options = append(c.Options, o)
 
 			} else {
@@ -94,19 +258,38 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 				Options: make([]*option, 0),
 				Subs:    make(map[string]context),
 			}
-			sub, err := reflectSomeMore(sub, v.Type(), &v)
+			sub, err := reflectSomeMore(sub, v.Type(), &v, index)
 			if err != nil {
 				return c, err
 			}
+			if sub.about, err = annotations(field); err != nil {
+				return c, err
+			}
 
-			for _, cmd := range regexp.MustCompile(" *, *").Split(tag, -1) {
-				if strings.HasSuffix(cmd, "!") {
-					sub.Stop = true
-					cmd = cmd[:len(cmd)-1]
+			for _, cmd := range splitter.Split(tag, -1) {
+				dflt := false
+				for {
+					if strings.HasSuffix(cmd, "!") {
+						sub.Stop = true
+						cmd = cmd[:len(cmd)-1]
+						continue
+					}
+					if strings.HasSuffix(cmd, "*") {
+						dflt = true
+						cmd = cmd[:len(cmd)-1]
+						continue
+					}
+					break
 				}
 				if sub.Command == "" {
 					sub.Command = cmd
 				}
+				if dflt {
+					if c.Default != "" && c.Default != sub.Command {
+						return c, fmt.Errorf("sub-commands `%s` and `%s` cannot both be the default", c.Default, sub.Command)
+					}
+					c.Default = sub.Command
+				}
 				c.Subs[cmd] = sub
 			}
 			break
@@ -114,16 +297,105 @@ func reflectSomeMore(c context, t reflect.Type, v *reflect.Value) (context, erro
 		default:
 			return c, fmt.Errorf("go-cli cannot operate on this type of thing")
 		}
+
+		if negatable {
+			if err := c.Options[len(c.Options)-1].negatable(); err != nil {
+				return c, fmt.Errorf("invalid negatable tag on field %s (%s)", field.Name, err)
+			}
+		}
+		if indirect {
+			if t.Kind() == reflect.Struct || c.Options[len(c.Options)-1].enableable() {
+				return c, fmt.Errorf("invalid indirect tag on field %s (only options that take values can be indirect)", field.Name)
+			}
+			c.Options[len(c.Options)-1].Indirect = true
+		}
+		if secret {
+			if t.Kind() == reflect.Struct || c.Options[len(c.Options)-1].enableable() {
+				return c, fmt.Errorf("invalid secret tag on field %s (only options that take values can be secret)", field.Name)
+			}
+			c.Options[len(c.Options)-1].Secret = true
+			c.Options[len(c.Options)-1].Default = nil
+		}
 	}
 
 	return c, nil
 }
 
-func newOption(typ reflect.Type, kind reflect.Kind, value *reflect.Value, tag string) (*option, error) {
-	splitter := regexp.MustCompile(" *, *")
-	short := regexp.MustCompile("^-([a-zA-Z0-9?])$")
-	long := regexp.MustCompile("^--([a-zA-Z0-9?][a-zA-Z0-9?-]+)$")
+/* annotations pulls the `hidden`, `deprecated` and `help` tags off
+   of a field, for use by both options and sub-commands.  Hidden things
+   still parse; they just don't get advertised.  Deprecated things
+   also still parse, but their use is recorded as a warning. */
+func annotations(field reflect.StructField) (about, error) {
+	var (
+		a   about
+		err error
+	)
 
+	if tag, set := field.Tag.Lookup("hidden"); set {
+		if a.Hidden, err = strconv.ParseBool(tag); err != nil {
+			return a, fmt.Errorf("invalid hidden tag '%s' on field %s", tag, field.Name)
+		}
+	}
+	if tag, set := field.Tag.Lookup("deprecated"); set {
+		a.Deprecated = &tag
+	}
+	a.Help = field.Tag.Get("help")
+	a.Field = field.Name
+	return a, nil
+}
+
+/* negatable generates the `no-` forms of each of the option's long
+   names (unless they are already there).  For booleans, these are
+   just like `no-` forms given in the cli tag, and turn the option off.
+   Everything else takes no value with them, and gets cleared out. */
+func (o *option) negatable() error {
+	if len(o.Longs) == 0 {
+		return fmt.Errorf("it has no long options to negate")
+	}
+
+	longs := o.Longs
+	for _, long := range longs {
+		if strings.HasPrefix(long, "no-") || contains(longs, "no-"+long) {
+			continue
+		}
+		o.Longs = append(o.Longs, "no-"+long)
+		if !o.enableable() {
+			o.Negations = append(o.Negations, "no-"+long)
+		}
+	}
+	return nil
+}
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+/* defaultOf formats the value an option field has before any
+   parsing happens (i.e. its default), or gives back nil if the
+   field is unset (zero, nil, or empty).  Secret options keep
+   their defaults to themselves. */
+func (o *option) defaultOf(v reflect.Value) *string {
+	if o.Secret {
+		return nil
+	}
+	if v.Kind() == reflect.Slice {
+		if v.Len() == 0 {
+			return nil
+		}
+	} else if v.IsZero() {
+		return nil
+	}
+
+	s := o.format(v)
+	return &s
+}
+
+func newOption(typ reflect.Type, kind reflect.Kind, value *reflect.Value, tag string) (*option, error) {
 	o := &option{
 		Init:   false,
 		Type:   typ,
@@ -131,18 +403,19 @@ func newOption(typ reflect.Type, kind reflect.Kind, value *reflect.Value, tag st
 		Value:  value,
 		Shorts: "",
 		Longs:  make([]string, 0),
+		Base:   10,
 	}
 
 	seen := make(map[string]bool) /* to de-dupe inside the tag spec */
 	for _, opt := range splitter.Split(tag, -1) {
-		if m := short.FindStringSubmatch(opt); m != nil {
+		if m := shortFlag.FindStringSubmatch(opt); m != nil {
 			if _, ok := seen[m[1]]; !ok {
 				o.Shorts = o.Shorts + m[1]
 				seen[m[1]] = true
 			}
 			continue
 		}
-		if m := long.FindStringSubmatch(opt); m != nil {
+		if m := longFlag.FindStringSubmatch(opt); m != nil {
 			if _, ok := seen[m[1]]; !ok {
 				o.Longs = append(o.Longs, m[1])
 				seen[m[1]] = true
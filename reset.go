@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"reflect"
+)
+
+/* save records the current value (and origin) of every option, from
+   this level on down, so that restore() can put them back the way
+   they were. */
+func (c context) save() {
+	for _, o := range c.Options {
+		o.SavedOrigin = o.Origin
+		o.SavedInit = o.Init
+		if o.Flag != nil {
+			o.Saved = reflect.ValueOf(o.Flag.String())
+			continue
+		}
+		o.Saved = clone(*o.Value)
+	}
+	for name, sub := range c.Subs {
+		if name == sub.Command {
+			sub.save()
+		}
+	}
+}
+
+/* restore puts back the saved values of the options along the given
+   sub-command path (top-level options are always on the path).  These
+   are the only options that parsing a command could have touched;
+   everything else is left as-is.
+
+   Mounted flags (see MountFlags()) are set back to what their String()
+   was, but only if that changed; Set() may well append, not replace. */
+func (c context) restore(cmd []string) {
+	for _, o := range c.Options {
+		o.Origin = o.SavedOrigin
+		o.Init = o.SavedInit
+		if o.Flag != nil {
+			if o.Flag.String() != o.Saved.String() {
+				o.Flag.Set(o.Saved.String())
+			}
+			continue
+		}
+		o.Value.Set(clone(o.Saved))
+	}
+	if len(cmd) > 0 {
+		c.Subs[cmd[0]].restore(cmd[1:])
+	}
+}
+
+/* restoreAll puts back the saved values of every option, on every path. */
+func (c context) restoreAll() {
+	c.restore(nil)
+	for name, sub := range c.Subs {
+		if name == sub.Command {
+			sub.restoreAll()
+		}
+	}
+}
+
+/* clone makes a deep copy of a value, so that whatever happens to
+   the original (or to the copy) doesn't show up in the other.  Pointers
+   are followed, and lists and maps get new storage; structures are
+   copied wholesale, and then their exported fields are cloned. */
+func clone(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			c.Set(reflect.New(v.Type().Elem()))
+			c.Elem().Set(clone(v.Elem()))
+		}
+
+	case reflect.Slice:
+		if !v.IsNil() {
+			c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				c.Index(i).Set(clone(v.Index(i)))
+			}
+		}
+
+	case reflect.Map:
+		if !v.IsNil() {
+			c.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+			for _, k := range v.MapKeys() {
+				c.SetMapIndex(clone(k), clone(v.MapIndex(k)))
+			}
+		}
+
+	case reflect.Struct:
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(clone(v.Field(i)))
+			}
+		}
+
+	default:
+		c.Set(v)
+	}
+	return c
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+/* ResponseFiles turns on the expansion of `@path` arguments.  Each
+   such argument is replaced by the words found in the file at path,
+   split according to the shell-like rules of words().  Response files
+   can themselves contain `@path` arguments; relative paths in a
+   response file are taken relative to the directory of that file.
+
+   If you need to pass an argument that really does start with an
+   at-sign, double it up: `@@handle` is passed along as `@handle`. */
+func ResponseFiles() Setting {
+	return func(s *settings) {
+		s.responses = true
+	}
+}
+
+/* expandResponses replaces all `@path` arguments with the contents of
+   their response files, before parse() ever gets a look at them.  The
+   origin of each of the resulting arguments is given back alongside. */
+func expandResponses(args []string) ([]string, []origin, error) {
+	out := make([]string, 0, len(args))
+	from := make([]origin, 0, len(args))
+	for i, arg := range args {
+		if !isResponse(arg) {
+			out = append(out, unescapeResponse(arg))
+			from = append(from, origin{Source: sourceArgv, Arg: i + 1})
+			continue
+		}
+
+		more, where, err := readResponse(arg[1:], "", nil, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range where {
+			where[j].Arg = i + 1
+		}
+		out = append(out, more...)
+		from = append(from, where...)
+	}
+	return out, from, nil
+}
+
+/* readResponse reads in the response file at path (relative to dir),
+   recursively expanding any response files it refers to.  The chain
+   of files that got us here is kept in seen, so that we can detect
+   (and complain about) cycles.  Error messages are prefixed with
+   where, which identifies the file and line doing the including.
+   The file and line that each argument came from is given back too. */
+func readResponse(path, dir string, seen []string, where string) ([]string, []origin, error) {
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%sunable to read response file `%s`: %s", where, path, err)
+	}
+	for i, s := range seen {
+		if s == abs {
+			return nil, nil, fmt.Errorf("%sresponse file `%s` includes itself (via %s)", where, path,
+				strings.Join(append(seen[i:], abs), " -> "))
+		}
+	}
+	seen = append(seen, abs)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%sunable to read response file `%s`: %s", where, path, err)
+	}
+
+	ws, err := words(string(b))
+	if err != nil {
+		if e, ok := err.(syntaxError); ok {
+			return nil, nil, fmt.Errorf("%s:%d: %s", path, e.line, e.problem)
+		}
+		return nil, nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	out := make([]string, 0, len(ws))
+	from := make([]origin, 0, len(ws))
+	for _, w := range ws {
+		if !isResponse(w.text) {
+			out = append(out, unescapeResponse(w.text))
+			from = append(from, origin{Source: sourceConfig, File: path, Line: w.line})
+			continue
+		}
+
+		more, inner, err := readResponse(w.text[1:], filepath.Dir(path), seen, fmt.Sprintf("%s:%d: ", path, w.line))
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, more...)
+		from = append(from, inner...)
+	}
+	return out, from, nil
+}
+
+func isResponse(arg string) bool {
+	return len(arg) > 1 && arg[0] == '@' && arg[1] != '@'
+}
+
+func unescapeResponse(arg string) string {
+	if strings.HasPrefix(arg, "@@") {
+		return arg[1:]
+	}
+	return arg
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	gocontext "context"
+	"errors"
+	"os"
+	"os/signal"
+)
+
+/* ErrCanceled is what Parser.Run() (and Parser.Error()) give back
+   when the context is canceled, or one of the signals asked for via
+   WithSignals() shows up, before the chain of commands is done. */
+var ErrCanceled = errors.New("canceled; skipping the rest of the chained commands")
+
+/* A ContextHandler runs a single (possibly chained) command on behalf
+   of Parser.Run().  It is given the same Command and Args that a call
+   to Parser.Next() would have set up, and a context that is canceled
+   if the rest of the chain is going to be skipped. */
+type ContextHandler func(ctx gocontext.Context, command string, args []string) error
+
+/* WithSignals makes Parser.Run() cancel its context when any of the
+   given signals (i.e. os.Interrupt) is received.  Until Run() returns,
+   those signals no longer get their default behavior. */
+func WithSignals(signals ...os.Signal) Setting {
+	return func(s *settings) {
+		s.signals = append(s.signals, signals...)
+	}
+}
+
+/* Run calls Next() until it runs out of chained commands, handing
+   each one off to the handler, along with a context derived from ctx.
+
+   If the context is canceled (or a signal is caught, see WithSignals()),
+   the remaining commands are skipped and Run() gives back ErrCanceled;
+   the command that was running gets to finish, and if it returns an
+   error, that error is taken to be a consequence of the cancellation.
+   Otherwise, the first error (from parsing, or from the handler) stops
+   the chain, and is given back.  Either way, Error() reports it too. */
+func (p *Parser) Run(ctx gocontext.Context, handler ContextHandler) error {
+	if len(p.s.signals) > 0 {
+		var cancel gocontext.CancelFunc
+		ctx, cancel = gocontext.WithCancel(ctx)
+		defer cancel()
+
+		caught := make(chan os.Signal, 1)
+		signal.Notify(caught, p.s.signals...)
+		defer signal.Stop(caught)
+
+		go func() {
+			select {
+			case <-caught:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	/* check before parsing, so that skipped commands
+	   don't get a chance to fail, or to do anything else */
+	for p.more() {
+		if ctx.Err() != nil {
+			p.err = ErrCanceled
+			break
+		}
+		if !p.Next() {
+			break
+		}
+		if err := handler(ctx, p.Command, p.Args); err != nil {
+			if ctx.Err() != nil {
+				p.err = ErrCanceled
+			} else {
+				p.err = err
+			}
+			break
+		}
+	}
+	return p.err
+}
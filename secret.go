@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* redacted stands in for the values of secret options,
+   wherever they would otherwise be shown. */
+const redacted = "<redacted>"
+
+/* hush keeps the value of a secret option out of the error
+   (if any) that came from setting it, via the given flag. */
+func (o *option) hush(flag string, err error) error {
+	if err == nil || !o.Secret {
+		return err
+	}
+	return fmt.Errorf("invalid value for `%s` flag (it is secret, so it isn't shown)", flag)
+}
+
+/* reveal gives back a formatted value of the option,
+   unless the option is secret, and show is false. */
+func (o *option) reveal(s string, show bool) string {
+	if o.Secret && !show {
+		return redacted
+	}
+	return s
+}
+
+/* hushFlag keeps the value out of the error for an unrecognized
+   `--name=value` flag, if name belongs to a secret option anywhere
+   in the structure; that's most likely someone trying to give it
+   its value the way some other program would have let them. */
+func (c context) hushFlag(name string, err error) error {
+	i := strings.IndexByte(name, '=')
+	if _, ok := err.(unrecognizedFlag); !ok || i < 0 || !c.secret(name[:i]) {
+		return err
+	}
+	return unrecognizedFlag("--" + name[:i] + "=" + redacted)
+}
+
+/* secret tells if a long option name belongs to a secret
+   option, at this level or any of the ones below it. */
+func (c context) secret(long string) bool {
+	for _, o := range c.Options {
+		if !o.Secret {
+			continue
+		}
+		for _, l := range o.Longs {
+			if l == long {
+				return true
+			}
+		}
+	}
+	for name, sub := range c.Subs {
+		if name == sub.Command && sub.secret(long) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+)
+
+/* A Setting tweaks how a Parser goes about its business.  Settings
+   are passed as trailing arguments to NewParser(), ParseArgs() and
+   Parse(), and are applied (in order) before any arguments are
+   looked at. */
+type Setting func(*settings)
+
+type settings struct {
+	warn      func(string)
+	responses bool
+	prompt    *string
+	history   string
+	signals   []os.Signal
+	strict    bool
+	negatives bool
+	single    bool
+	mounts    []mount
+	limit     *ByteSize
+}
+
+func configure(given []Setting) settings {
+	var s settings
+	for _, fn := range given {
+		fn(&s)
+	}
+	return s
+}
+
+/* WarnTo hands every warning the Parser records (i.e. for the use of
+   deprecated options or sub-commands) to the given function, as soon
+   as it is recorded.  Warnings are still kept in Parser.Warnings. */
+func WarnTo(fn func(string)) Setting {
+	return func(s *settings) {
+		s.warn = fn
+	}
+}
+
+/* StrictOrder makes the Parser insist that options come before any
+   positional arguments, the way getopt does when POSIXLY_CORRECT is
+   set.  Once a sub-command (or the top-level) has seen its first
+   positional argument, everything up to the end of the command (or
+   the next `--` in a chain) is taken as-is, even if it looks like a
+   flag.  Sub-commands are still recognized, as long as they come
+   before the first positional argument. */
+func StrictOrder() Setting {
+	return func(s *settings) {
+		s.strict = true
+	}
+}
+
+/* NegativeNumbers lets arguments like `-5` and `-2.5` through as
+   positional arguments, instead of complaining about unrecognized
+   flags, unless there is a short option by that name (i.e. `-5`)
+   in scope.  (Negative numbers given as flag values, like in
+   `--offset -5`, always work, setting or no setting.) */
+func NegativeNumbers() Setting {
+	return func(s *settings) {
+		s.negatives = true
+	}
+}
+
+/* SingleDashLongs lets long options be given with a single dash, the
+   way the standard library's flag package wants them (i.e. `-url x`,
+   `-url=x` or `-insecure`); `--url=x` works too.  An argument like
+   `-name` is looked up as a long option first, and only read as a
+   bundle of short options if there is no such long option in scope.
+   Structures that have long options that could be mistaken for a
+   bundle of short options are rejected by NewParser(). */
+func SingleDashLongs() Setting {
+	return func(s *settings) {
+		s.single = true
+	}
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+/* A Handler runs a single (possibly chained) command on behalf
+   of a Shell.  It is given the same Command and Args that a call
+   to Parser.Next() would have set up. */
+type Handler func(command string, args []string) error
+
+/* Prompt sets the prompt that a Shell prints before reading each
+   line.  The default prompt is "> ". */
+func Prompt(prompt string) Setting {
+	return func(s *settings) {
+		s.prompt = &prompt
+	}
+}
+
+/* History tells a Shell to append every line it reads to the given
+   file, and to load up any lines already in it, so that history
+   persists between sessions. */
+func History(path string) Setting {
+	return func(s *settings) {
+		s.history = path
+	}
+}
+
+/* Shell runs an interactive read-eval-print loop, driven by the
+   options structure.  Each line read from in is split into words
+   (see Split()) and parsed as if it were a full command line, chains
+   and all.  Every command found is handed off to the handler; any
+   errors (from parsing, or from the handler) are printed to out, and
+   cause the rest of that line's chain to be skipped.
+
+   Before each line, the options in the structure are reset to the
+   values they had when Shell() was called, so that flags given on one
+   line do not bleed into the next.
+
+   A few built-in commands are understood, unless the options structure
+   defines sub-commands of the same name:
+
+     help      lists the available sub-commands
+     history   prints the command history
+     exit      leaves the shell (as does quit, or end-of-file)
+
+   Shell() returns when it runs out of input, or when told to exit. */
+func Shell(thing interface{}, handler Handler, in io.Reader, out io.Writer, settings ...Setting) error {
+	c, err := inspect(thing)
+	if err != nil {
+		return err
+	}
+
+	s := configure(settings)
+	if c, err = c.mounted(s.mounts); err != nil {
+		return err
+	}
+	c.save()
+
+	prompt := "> "
+	if s.prompt != nil {
+		prompt = *s.prompt
+	}
+
+	history := []string{}
+	var record io.Writer
+	if s.history != "" {
+		if b, err := ioutil.ReadFile(s.history); err == nil && len(b) > 0 {
+			history = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		}
+		f, err := os.OpenFile(s.history, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("unable to open history file `%s`: %s", s.history, err)
+		}
+		defer f.Close()
+		record = f
+	}
+
+	lines := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, prompt)
+		if !lines.Scan() {
+			break
+		}
+
+		line := lines.Text()
+		args, err := Split(line)
+		if err != nil {
+			fmt.Fprintf(out, "!!! %s\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		history = append(history, line)
+		if record != nil {
+			fmt.Fprintf(record, "%s\n", line)
+		}
+
+		if _, defined := c.Subs[args[0]]; !defined {
+			switch args[0] {
+			case "exit", "quit":
+				return nil
+
+			case "help":
+				fmt.Fprintf(out, "commands:\n")
+				c.usage(out, "")
+				continue
+
+			case "history":
+				for i, h := range history {
+					fmt.Fprintf(out, "%5d  %s\n", i+1, h)
+				}
+				continue
+			}
+		}
+
+		c.restoreAll()
+
+		p, err := NewParser(thing, args, settings...)
+		if err != nil {
+			fmt.Fprintf(out, "!!! %s\n", err)
+			continue
+		}
+		for p.Next() {
+			if err := handler(p.Command, p.Args); err != nil {
+				fmt.Fprintf(out, "!!! %s\n", err)
+				break
+			}
+		}
+		if err := p.Error(); err != nil {
+			fmt.Fprintf(out, "!!! %s\n", err)
+		}
+	}
+	fmt.Fprintln(out)
+
+	return lines.Err()
+}
+
+/* usage prints the (non-hidden) sub-commands available from this
+   level down, one per line, with their aliases. */
+func (c context) usage(out io.Writer, parent string) {
+	for _, name := range c.commands() {
+		sub := c.Subs[name]
+		if sub.Hidden {
+			continue
+		}
+
+		full := strings.TrimSpace(parent + " " + name)
+		if aliases := c.aliases(name)[1:]; len(aliases) > 0 {
+			fmt.Fprintf(out, "  %s (%s)\n", full, strings.Join(aliases, ", "))
+		} else {
+			fmt.Fprintf(out, "  %s\n", full)
+		}
+		sub.usage(out, full)
+	}
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+/* A CommandSpec describes one level of a program's command tree: the
+   program itself (which has no Name), or one of its sub-commands.  It
+   is the exported, machine-readable form of what go-cli reflects out
+   of an options structure, for tooling that needs to know what
+   commands and flags exist. */
+type CommandSpec struct {
+	Name       string         `json:"name"`            /* canonical name */
+	Field      string         `json:"field,omitempty"` /* name of the structure field */
+	Aliases    []string       `json:"aliases"`         /* other names, besides Name */
+	Help       string         `json:"help,omitempty"`
+	Stop       bool           `json:"stop"`              /* no option processing after this */
+	Default    string         `json:"default,omitempty"` /* default sub-command */
+	Hidden     bool           `json:"hidden"`
+	Deprecated *string        `json:"deprecated,omitempty"`
+	Options    []*OptionSpec  `json:"options"`
+	Commands   []*CommandSpec `json:"commands"`
+}
+
+/* An OptionSpec describes a single option (flag).  Kind names the Go
+   kind of the value (i.e. "string", "int32" or "bool"); for lists, it
+   is the kind of the list elements, and Repeatable is set.  Options
+   that don't take a value argument are the ones that can be turned on
+   (and maybe off) by their mere presence.  Byte sizes and percentages
+   (see ByteSize and Percent) keep their Kind, and set Unit; integers
+   that aren't given in base 10 set Base.  Options declared with a
+   named type (like `type Level int`) keep its underlying Kind, and
+   name the type in Type.  Secret options never have a Default. */
+type OptionSpec struct {
+	Field      string      `json:"field"`
+	Kind       string      `json:"kind"`
+	Type       string      `json:"type,omitempty"` /* for named types, i.e. "Level" */
+	Shorts     []string    `json:"shorts"`
+	Longs      []string    `json:"longs"`
+	Value      bool        `json:"value"`               /* takes a value argument */
+	Repeatable bool        `json:"repeatable"`          /* can be given more than once */
+	Separator  string      `json:"separator,omitempty"` /* for delimited lists */
+	Negations  []string    `json:"negations,omitempty"` /* take no value, and clear it */
+	Unit       string      `json:"unit,omitempty"`      /* "bytes" or "percent" */
+	Base       string      `json:"base,omitempty"`      /* for integers: "0" (by prefix), "16", etc. */
+	Indirect   bool        `json:"indirect,omitempty"`  /* can come from @files, stdin or env:VARS */
+	Secret     bool        `json:"secret,omitempty"`    /* values are never shown */
+	Nullable   bool        `json:"nullable"`            /* can be left unset (pointers) */
+	Default    interface{} `json:"default,omitempty"`
+	Min        json.Number `json:"min,omitempty"`
+	Max        json.Number `json:"max,omitempty"`
+	Help       string      `json:"help,omitempty"`
+	Hidden     bool        `json:"hidden"`
+	Deprecated *string     `json:"deprecated,omitempty"`
+}
+
+/* Spec describes the command tree defined by an options structure.
+   Hidden options and sub-commands are included (and marked as such);
+   sub-commands are sorted by name, and options keep the order they
+   were defined in.  Whatever values are in the structure when Spec()
+   is called are reported as the defaults. */
+func Spec(thing interface{}) (*CommandSpec, error) {
+	c, err := inspect(thing)
+	if err != nil {
+		return nil, err
+	}
+	return c.spec(nil), nil
+}
+
+func (c context) spec(aliases []string) *CommandSpec {
+	s := &CommandSpec{
+		Name:       c.Command,
+		Field:      c.Field,
+		Aliases:    aliases,
+		Help:       c.Help,
+		Stop:       c.Stop,
+		Default:    c.Default,
+		Hidden:     c.Hidden,
+		Deprecated: c.Deprecated,
+		Options:    make([]*OptionSpec, 0, len(c.Options)),
+		Commands:   make([]*CommandSpec, 0, len(c.Subs)),
+	}
+	if s.Aliases == nil {
+		s.Aliases = []string{}
+	}
+
+	for _, o := range c.Options {
+		s.Options = append(s.Options, o.spec())
+	}
+	for _, name := range c.commands() {
+		s.Commands = append(s.Commands, c.Subs[name].spec(c.aliases(name)[1:]))
+	}
+	return s
+}
+
+func (o *option) spec() *OptionSpec {
+	t := o.Type
+	s := &OptionSpec{
+		Field:      o.Field,
+		Shorts:     make([]string, 0, len(o.Shorts)),
+		Longs:      make([]string, 0, len(o.Longs)),
+		Value:      !o.enableable(),
+		Repeatable: t.Kind() == reflect.Slice,
+		Separator:  o.Sep,
+		Negations:  o.Negations,
+		Unit:       o.Unit,
+		Indirect:   o.Indirect,
+		Secret:     o.Secret,
+		Nullable:   t.Kind() == reflect.Ptr,
+		Help:       o.Help,
+		Hidden:     o.Hidden,
+		Deprecated: o.Deprecated,
+	}
+	for _, short := range o.Shorts {
+		s.Shorts = append(s.Shorts, string(short))
+	}
+	for _, long := range o.Longs {
+		if !o.negates(long) {
+			s.Longs = append(s.Longs, long)
+		}
+	}
+	if o.Default != nil {
+		s.Default = o.Value.Interface()
+	}
+
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	s.Kind = t.Kind().String()
+	if t.PkgPath() != "" {
+		s.Type = t.Name()
+	}
+	if integral(t) && o.Base != 10 {
+		s.Base = strconv.Itoa(o.Base)
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := uint(t.Bits())
+		s.Min = json.Number(strconv.FormatInt(-1<<(bits-1), 10))
+		s.Max = json.Number(strconv.FormatInt(1<<(bits-1)-1, 10))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Min = json.Number("0")
+		s.Max = json.Number(strconv.FormatUint(1<<uint(t.Bits())-1, 10))
+	}
+	return s
+}
+
+/* MarshalJSON renders the command tree as JSON.  Lists are always
+   rendered as lists (never as null), even in hand-built specs, so that
+   consumers don't have to check. */
+func (c *CommandSpec) MarshalJSON() ([]byte, error) {
+	type plain CommandSpec
+	p := plain(*c)
+	if p.Aliases == nil {
+		p.Aliases = []string{}
+	}
+	if p.Options == nil {
+		p.Options = []*OptionSpec{}
+	}
+	if p.Commands == nil {
+		p.Commands = []*CommandSpec{}
+	}
+	return json.Marshal(p)
+}
+
+/* JSONSchema renders a JSON Schema (draft 7) that describes the
+   option values for the command tree, as they would be laid out by
+   encoding/json: an object keyed by structure field name, with each
+   sub-command as a nested object. */
+func (c *CommandSpec) JSONSchema() ([]byte, error) {
+	s := c.schema()
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func (c *CommandSpec) schema() map[string]interface{} {
+	props := make(map[string]interface{})
+	for _, o := range c.Options {
+		props[o.Field] = o.schema()
+	}
+	for _, sub := range c.Commands {
+		props[sub.Field] = sub.schema()
+	}
+
+	s := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if c.Name != "" {
+		s["title"] = c.Name
+	}
+	if c.Help != "" {
+		s["description"] = c.Help
+	}
+	if c.Deprecated != nil {
+		s["deprecated"] = true
+	}
+	return s
+}
+
+func (o *OptionSpec) schema() map[string]interface{} {
+	s := map[string]interface{}{}
+	switch o.Kind {
+	case "bool":
+		s["type"] = "boolean"
+	case "string":
+		s["type"] = "string"
+	case "float32", "float64":
+		s["type"] = "number"
+	default:
+		s["type"] = "integer"
+	}
+	if o.Min != "" {
+		s["minimum"] = o.Min
+	}
+	if o.Max != "" {
+		s["maximum"] = o.Max
+	}
+
+	if o.Repeatable {
+		s = map[string]interface{}{
+			"type":  "array",
+			"items": s,
+		}
+	}
+	if o.Nullable {
+		s["type"] = []interface{}{s["type"], "null"}
+	}
+
+	if o.Help != "" {
+		s["description"] = o.Help
+	}
+	if o.Default != nil {
+		s["default"] = o.Default
+	}
+	if o.Deprecated != nil {
+		s["deprecated"] = true
+	}
+	if o.Secret {
+		s["writeOnly"] = true
+	}
+	return s
+}
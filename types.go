@@ -1,29 +1,94 @@
 package cli
 
 import (
+	"flag"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+/* about holds the descriptive bits that options
+   and sub-commands have in common. */
+type about struct {
+	Field      string /* name of the structure field */
+	Help       string
+	Hidden     bool
+	Deprecated *string
+}
+
 type option struct {
+	about
+
 	Init    bool
 	Type    reflect.Type
 	Kind    reflect.Kind
 	Value   *reflect.Value
+	Saved   reflect.Value /* what to restore Value to, between commands */
 	Default *string
 	Shorts  string
 	Longs   []string
+	Index   []int      /* field index path, from the top-level structure */
+	Flag    flag.Value /* for options mounted from a flag.FlagSet */
+	Sep     string     /* for lists that take delimited values */
+	Unit    string     /* for byte sizes and percentages */
+	Base    int        /* for integers; 0 means any, by prefix */
+
+	Negations []string /* generated `no-` longs, that clear (non-boolean) options */
+	Indirect  bool     /* values can come from files, stdin or the environment */
+	Secret    bool     /* values are never shown, in errors, help or otherwise */
+
+	Origin      origin /* where Value came from; see Parser.Dump() */
+	SavedOrigin origin /* what to restore Origin to, between commands */
+	SavedInit   bool   /* what to restore Init to, between commands */
 }
 
 type context struct {
+	about
+
 	Command string /* canonical name */
+	Default string /* canonical name of the default sub-command */
 	Stop    bool
 	Options []*option
 	Subs    map[string]context
 }
 
+/* commands returns the canonical names of the sub-commands
+   defined at this level, in sorted order. */
+func (c context) commands() []string {
+	l := make([]string, 0, len(c.Subs))
+	for name, sub := range c.Subs {
+		if name == sub.Command {
+			l = append(l, name)
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+/* aliases returns all of the names that a sub-command at this
+   level answers to, canonical name first, the rest sorted. */
+func (c context) aliases(command string) []string {
+	l := make([]string, 0)
+	for name, sub := range c.Subs {
+		if sub.Command == command && name != command {
+			l = append(l, name)
+		}
+	}
+	sort.Strings(l)
+	return append([]string{command}, l...)
+}
+
+/* unrecognizedFlag is the error given back when a flag can't be found;
+   it is a distinct type so that parsing can fall back to a default
+   sub-command, which may know what to do with the flag. */
+type unrecognizedFlag string
+
+func (f unrecognizedFlag) Error() string {
+	return fmt.Sprintf("unrecognized flag `%s`", string(f))
+}
+
 func (c context) findLong(subs []string, name string) (*option, error) {
 	/* try the options on this level */
 	for _, o := range c.Options {
@@ -36,7 +101,7 @@ func (c context) findLong(subs []string, name string) (*option, error) {
 
 	/* if we have no more sub-commands to descend into, we're hooped */
 	if len(subs) == 0 {
-		return nil, fmt.Errorf("unrecognized flag `--%s`", name)
+		return nil, unrecognizedFlag("--" + name)
 	}
 
 	if sub, ok := c.Subs[subs[0]]; ok {
@@ -57,7 +122,7 @@ func (c context) findShort(subs []string, name string) (*option, error) {
 
 	/* if we have no more sub-commands to descend into, we're hooped */
 	if len(subs) == 0 {
-		return nil, fmt.Errorf("unrecognized flag `-%s`", name)
+		return nil, unrecognizedFlag("-" + name)
 	}
 
 	if sub, ok := c.Subs[subs[0]]; ok {
@@ -73,12 +138,40 @@ func (o *option) enableable() bool {
 }
 
 func (o *option) enable(on bool) {
+	if o.Flag != nil {
+		o.Flag.Set(strconv.FormatBool(on))
+		return
+	}
 	if o.Kind == reflect.Ptr {
 		o.Value.Set(reflect.New(o.Type.Elem()))
-		o.Value.Elem().Set(reflect.ValueOf(on))
+		o.Value.Elem().Set(reflect.ValueOf(on).Convert(o.Type.Elem()))
 	} else {
-		o.Value.Set(reflect.ValueOf(on))
+		o.Value.Set(reflect.ValueOf(on).Convert(o.Type))
+	}
+}
+
+/* negates figures out if the given long name is one of
+   the `no-` forms generated for a `negatable` option. */
+func (o *option) negates(name string) bool {
+	for _, l := range o.Negations {
+		if l == name {
+			return true
+		}
 	}
+	return false
+}
+
+/* negate clears an option: lists are emptied out (and
+   stay that way, defaults and all, until they are set again),
+   unbound pointers go back to nil, and everything else gets
+   its zero value. */
+func (o *option) negate() {
+	if o.Kind == reflect.Slice {
+		o.Init = true
+		o.Value.Set(reflect.MakeSlice(o.Value.Type(), 0, 0))
+		return
+	}
+	o.Value.Set(reflect.Zero(o.Value.Type()))
 }
 
 func (o *option) set(raw string) error {
@@ -87,18 +180,31 @@ func (o *option) set(raw string) error {
 		err error
 	)
 
+	if o.Flag != nil {
+		return o.Flag.Set(raw)
+	}
 	if o.Kind == reflect.Slice {
-		v, err = valify(raw, o.Value.Type().Elem().Kind())
+		values := []string{raw}
+		if o.Sep != "" {
+			values = split(raw, o.Sep)
+		}
+
+		l := make([]reflect.Value, len(values))
+		for i := range values {
+			if l[i], err = o.valify(values[i], o.Value.Type().Elem()); err != nil {
+				return err
+			}
+		}
 		if !o.Init {
 			o.Init = true
-			v = reflect.Append(reflect.MakeSlice(o.Value.Type(), 0, 0), v)
+			v = reflect.Append(reflect.MakeSlice(o.Value.Type(), 0, 0), l...)
 		} else {
-			v = reflect.Append(*o.Value, v)
+			v = reflect.Append(*o.Value, l...)
 		}
 	} else if o.Kind == reflect.Ptr {
-		v, err = valify(raw, o.Type.Elem().Kind())
+		v, err = o.valify(raw, o.Type.Elem())
 	} else {
-		v, err = valify(raw, o.Kind)
+		v, err = o.valify(raw, o.Type)
 	}
 	if err != nil {
 		return err
@@ -113,6 +219,122 @@ func (o *option) set(raw string) error {
 	return nil
 }
 
+/* valify converts a raw value into a value of the given type (which
+   may be a named type, like ByteSize), minding the option's units. */
+func (o *option) valify(raw string, t reflect.Type) (reflect.Value, error) {
+	var (
+		v   reflect.Value
+		err error
+	)
+
+	switch {
+	case o.Unit == bytesUnit:
+		v, err = bytify(raw, t)
+	case o.Unit == percentUnit:
+		v, err = percentify(raw, t)
+	case o.Base != 10 && integral(t):
+		v, err = baseify(raw, o.Base, t)
+	default:
+		v, err = valify(raw, t.Kind())
+	}
+	if err != nil {
+		return v, err
+	}
+	return v.Convert(t), nil
+}
+
+/* split breaks a delimited list value up into its elements.  A
+   backslash escapes the separator (or another backslash); any other
+   backslash is taken literally.  The empty string is the empty list. */
+func split(raw, sep string) []string {
+	l := make([]string, 0)
+	if raw == "" {
+		return l
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(raw); {
+		if raw[i] == '\\' && strings.HasPrefix(raw[i+1:], sep) {
+			b.WriteString(sep)
+			i += 1 + len(sep)
+			continue
+		}
+		if raw[i] == '\\' && strings.HasPrefix(raw[i+1:], "\\") {
+			b.WriteByte('\\')
+			i += 2
+			continue
+		}
+		if strings.HasPrefix(raw[i:], sep) {
+			l = append(l, b.String())
+			b.Reset()
+			i += len(sep)
+			continue
+		}
+		b.WriteByte(raw[i])
+		i++
+	}
+	return append(l, b.String())
+}
+
+/* join is the opposite of split(). */
+func join(l []string, sep string) string {
+	escaped := make([]string, len(l))
+	for i, s := range l {
+		s = strings.Replace(s, "\\", "\\\\", -1)
+		escaped[i] = strings.Replace(s, sep, "\\"+sep, -1)
+	}
+	return strings.Join(escaped, sep)
+}
+
+/* format renders a value the way valify() would want to see it;
+   lists are comma-separated, and nil pointers are empty.  Byte sizes
+   and percentages (per the unit) get their suffixes, and integers are
+   written in the option's base. */
+func (o *option) format(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return o.format(v.Elem())
+
+	case reflect.Slice:
+		l := make([]string, v.Len())
+		for i := range l {
+			l[i] = o.format(v.Index(i))
+		}
+		return strings.Join(l, ", ")
+
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if o.Unit == bytesUnit && v.Int() >= 0 {
+			return ByteSize(v.Int()).String()
+		}
+		if v.Int() < 0 {
+			return "-" + based(uint64(-v.Int()), o.Base)
+		}
+		return based(uint64(v.Int()), o.Base)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if o.Unit == bytesUnit {
+			return ByteSize(v.Uint()).String()
+		}
+		return based(v.Uint(), o.Base)
+
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+
+	case reflect.Float64:
+		if o.Unit == percentUnit {
+			return Percent(v.Float()).String()
+		}
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return v.String()
+}
+
 func valify(raw string, t reflect.Kind) (reflect.Value, error) {
 	var (
 		err error
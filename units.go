@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/* A ByteSize is a number of bytes.  On the command-line, it can be
+   given with an SI suffix (kB, MB, GB, TB, PB or EB; powers of 1000) or
+   an IEC suffix (KiB, MiB, GiB, TiB, PiB or EiB; powers of 1024), like
+   `10GiB` or `1.5MB`.  The trailing B is optional, case doesn't matter,
+   and a `/s` on the end (as in `100MB/s`) is allowed, for rates.
+
+   Integer fields of any width can be treated the same way, by giving
+   them a `unit:"bytes"` tag. */
+type ByteSize uint64
+
+/* String renders a ByteSize in the largest unit (SI or IEC) that
+   it is a whole multiple of, i.e. `10GiB`, `250MB` or `1023B`. */
+func (b ByteSize) String() string {
+	if b != 0 {
+		for _, u := range byteUnits {
+			if uint64(b)%u.size == 0 {
+				return fmt.Sprintf("%d%s", uint64(b)/u.size, u.suffix)
+			}
+		}
+	}
+	return fmt.Sprintf("%dB", uint64(b))
+}
+
+/* A Percent is a percentage.  On the command-line, it is given as a
+   number, with or without a trailing percent sign: `85%`, `12.5`.
+   It holds the number of percent (85, not 0.85); see Fraction(). */
+type Percent float64
+
+/* String renders a Percent with its percent sign, i.e. `85%`. */
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p), 'g', -1, 64) + "%"
+}
+
+/* Fraction gives back the percentage as a fraction of one. */
+func (p Percent) Fraction() float64 {
+	return float64(p) / 100
+}
+
+const (
+	bytesUnit   = "bytes"
+	percentUnit = "percent"
+)
+
+var (
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	percentType  = reflect.TypeOf(Percent(0))
+)
+
+/* byteUnits are the suffixes that a byte size can be rendered
+   with, largest first; the SI and IEC units are interleaved. */
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"EiB", 1 << 60}, {"EB", 1e18},
+	{"PiB", 1 << 50}, {"PB", 1e15},
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"kB", 1e3},
+}
+
+/* unitOf figures out which units (if any) an option of the
+   given type deals in, based on its `unit` tag and its type. */
+func unitOf(field reflect.StructField, t reflect.Type) (string, error) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	tag, set := field.Tag.Lookup("unit")
+	if !set {
+		switch t {
+		case byteSizeType:
+			return bytesUnit, nil
+		case percentType:
+			return percentUnit, nil
+		}
+		return "", nil
+	}
+
+	if tag != bytesUnit {
+		return "", fmt.Errorf("invalid unit tag '%s' on field %s", tag, field.Name)
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bytesUnit, nil
+	}
+	return "", fmt.Errorf("invalid unit tag '%s' on field %s (only integers can be byte sizes)", tag, field.Name)
+}
+
+/* bytify parses a byte size, i.e. `10GiB`, into an integer of the given
+   type, making sure that it fits.  Fractions are fine, as long as they
+   work out to a whole number of bytes (`1.5KiB` does; `1.0001kB` doesn't). */
+func bytify(raw string, t reflect.Type) (reflect.Value, error) {
+	s := strings.TrimSuffix(strings.TrimSpace(raw), "/s")
+	i := strings.IndexFunc(s, func(c rune) bool {
+		return !(c == '.' || (c >= '0' && c <= '9'))
+	})
+	if i < 0 {
+		i = len(s)
+	}
+
+	size, ok := bytesIn(strings.TrimSpace(s[i:]))
+	if !ok || i == 0 {
+		return reflect.ValueOf(nil), fmt.Errorf("invalid byte size `%s`", raw)
+	}
+	n, ok := new(big.Rat).SetString(s[:i])
+	if !ok {
+		return reflect.ValueOf(nil), fmt.Errorf("invalid byte size `%s`", raw)
+	}
+	n.Mul(n, new(big.Rat).SetInt(new(big.Int).SetUint64(size)))
+	if !n.IsInt() {
+		return reflect.ValueOf(nil), fmt.Errorf("invalid byte size `%s` (not a whole number of bytes)", raw)
+	}
+
+	bits := t.Bits()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.Num().BitLen() > bits-1 {
+			return reflect.ValueOf(nil), fmt.Errorf("byte size `%s` is out of range", raw)
+		}
+		return reflect.ValueOf(n.Num().Int64()).Convert(t), nil
+	}
+	if n.Num().BitLen() > bits {
+		return reflect.ValueOf(nil), fmt.Errorf("byte size `%s` is out of range", raw)
+	}
+	return reflect.ValueOf(n.Num().Uint64()).Convert(t), nil
+}
+
+/* bytesIn gives back the number of bytes in a unit (i.e. `MiB`). */
+func bytesIn(unit string) (uint64, bool) {
+	unit = strings.TrimSuffix(strings.ToUpper(unit), "B")
+	if unit == "" {
+		return 1, true
+	}
+
+	base := uint64(1000)
+	if len(unit) == 2 && unit[1] == 'I' {
+		base, unit = 1024, unit[:1]
+	}
+	if len(unit) != 1 {
+		return 0, false
+	}
+	power := strings.IndexByte("KMGTPE", unit[0])
+	if power < 0 {
+		return 0, false
+	}
+
+	size := uint64(1)
+	for ; power >= 0; power-- {
+		size *= base
+	}
+	return size, true
+}
+
+/* percentify parses a percentage, i.e. `85%`, into a
+   floating point number of the given type. */
+func percentify(raw string, t reflect.Type) (reflect.Value, error) {
+	s := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(raw), "%"))
+	f, err := strconv.ParseFloat(s, t.Bits())
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return reflect.ValueOf(nil), fmt.Errorf("percentage `%s` is out of range", raw)
+		}
+		return reflect.ValueOf(nil), fmt.Errorf("invalid percentage `%s`", raw)
+	}
+	return reflect.ValueOf(f).Convert(t), nil
+}
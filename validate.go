@@ -25,13 +25,15 @@ func validateLevel(c context, parents []string, shorts string, longs map[string]
 		}
 	}
 
-	for cmd, sub := range c.Subs {
+	/* aliases share their options, so check each sub-command once,
+	   by its canonical name, so that errors come out the same way */
+	for _, cmd := range c.commands() {
 		copied := make(map[string]bool, len(longs))
 		for k, v := range longs {
 			copied[k] = v
 		}
 
-		if err := validateLevel(sub, append(parents, cmd), shorts, copied); err != nil {
+		if err := validateLevel(c.Subs[cmd], append(parents, cmd), shorts, copied); err != nil {
 			return err
 		}
 	}
@@ -42,3 +44,59 @@ func validateLevel(c context, parents []string, shorts string, longs map[string]
 func validate(c context) error {
 	return validateLevel(c, make([]string, 0), "", make(map[string]bool))
 }
+
+/* validateSingleDash checks that none of the long options in scope
+   at the given context (or at any sub-command under it) could be
+   mistaken for a bundle of short options, when spelled with a single
+   dash, as they are allowed to be under SingleDashLongs(). */
+func validateSingleDash(c context, parents []string, scope []*option) error {
+	where := "(at global level)"
+	if len(parents) > 0 {
+		where = fmt.Sprintf("(in `%s` sub-command)", strings.Join(parents, " "))
+	}
+
+	scope = append(append([]*option{}, scope...), c.Options...)
+	for _, o := range scope {
+		for _, long := range o.Longs {
+			if shorts, ok := bundle(scope, long); ok {
+				return fmt.Errorf("long option `--%s` is ambiguous when given as `-%s`, which could also mean `%s` %s", long, long, shorts, where)
+			}
+		}
+	}
+
+	for _, cmd := range c.commands() {
+		if err := validateSingleDash(c.Subs[cmd], append(parents, cmd), scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* bundle figures out how a run of characters would be read as a bundle
+   of short options, if it can be, and gives back that reading. */
+func bundle(scope []*option, s string) (string, bool) {
+	l := make([]string, 0)
+	for len(s) > 0 {
+		var found *option
+		for _, o := range scope {
+			if strings.IndexByte(o.Shorts, s[0]) >= 0 {
+				found = o
+				break
+			}
+		}
+		if found == nil {
+			return "", false
+		}
+
+		l = append(l, "-"+s[:1])
+		s = s[1:]
+		if !found.enableable() {
+			/* the rest of the bundle is the value */
+			if len(s) > 0 {
+				l = append(l, s)
+			}
+			break
+		}
+	}
+	return strings.Join(l, " "), true
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* a word is a single shell-style token, and the (1-based)
+   line number of the input that it started on. */
+type word struct {
+	text string
+	line int
+}
+
+/* a syntaxError is what words() hands back when it can't
+   make sense of its input, along with where things went awry. */
+type syntaxError struct {
+	line    int
+	problem string
+}
+
+func (e syntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.problem)
+}
+
+/* words splits a string into words, according to (a subset of)
+   the POSIX shell rules:
+
+     - unquoted whitespace (including newlines) separates words
+     - a '#' at the start of a word comments out the rest of the line
+     - single quotes preserve everything between them, literally
+     - double quotes preserve everything but backslash escapes of
+       '$', '`', '"', '\' and newline
+     - outside of quotes, a backslash preserves the next character
+     - a backslash-newline pair is a line continuation, and is removed
+
+   No expansion (variables, globs, tildes, etc.) is ever performed. */
+func words(s string) ([]word, error) {
+	var (
+		out   []word
+		buf   []rune
+		in    bool /* are we in the middle of a word? */
+		line  = 1
+		start = 1
+	)
+
+	emit := func() {
+		if in {
+			out = append(out, word{text: string(buf), line: start})
+		}
+		buf = buf[:0]
+		in = false
+	}
+	begin := func() {
+		if !in {
+			in = true
+			start = line
+		}
+	}
+
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		switch {
+		case c == '\n':
+			emit()
+			line++
+
+		case c == ' ' || c == '\t' || c == '\r':
+			emit()
+
+		case c == '#' && !in:
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			i-- /* let the newline case handle the newline */
+
+		case c == '\\':
+			if i+1 >= len(rs) {
+				return nil, syntaxError{line, "dangling backslash at end of input"}
+			}
+			i++
+			if rs[i] == '\n' {
+				line++
+				continue
+			}
+			begin()
+			buf = append(buf, rs[i])
+
+		case c == '\'':
+			begin()
+			opened := line
+			for i++; i < len(rs) && rs[i] != '\''; i++ {
+				if rs[i] == '\n' {
+					line++
+				}
+				buf = append(buf, rs[i])
+			}
+			if i >= len(rs) {
+				return nil, syntaxError{opened, "unterminated single-quoted string"}
+			}
+
+		case c == '"':
+			begin()
+			opened := line
+			for i++; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					switch rs[i+1] {
+					case '$', '`', '"', '\\':
+						i++
+					case '\n':
+						i++
+						line++
+						continue
+					}
+				}
+				if rs[i] == '\n' {
+					line++
+				}
+				buf = append(buf, rs[i])
+			}
+			if i >= len(rs) {
+				return nil, syntaxError{opened, "unterminated double-quoted string"}
+			}
+
+		default:
+			begin()
+			buf = append(buf, c)
+		}
+	}
+	emit()
+	return out, nil
+}
+
+/* Split breaks a command string up into arguments, following the
+   POSIX shell's quoting rules (single quotes, double quotes and
+   backslashes), but without performing any sort of expansion. */
+func Split(s string) ([]string, error) {
+	ws, err := words(s)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, len(ws))
+	for i, w := range ws {
+		args[i] = w.text
+	}
+	return args, nil
+}
+
+/* Quote is the inverse of Split; it renders a list of arguments as a
+   single command string, quoting only those arguments that need it,
+   such that a POSIX shell (or Split) will give back the same list.
+   This makes it handy for logging commands. */
+func Quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, c := range s {
+		if !safe(c) {
+			return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+		}
+	}
+	return s
+}
+
+/* safe reports whether a character can appear in an unquoted
+   word without the shell (or Split) doing anything weird to it. */
+func safe(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		strings.ContainsRune("@%+=:,./_-", c)
+}